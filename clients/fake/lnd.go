@@ -0,0 +1,691 @@
+package fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+const (
+	// LocalPubKey of the fake local lnd node
+	LocalPubKey = "02aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	// RemotePubKey of the fake Xena lnd node
+	RemotePubKey = "03bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	// FundingConfirmations of funding transaction of confirmed channels
+	FundingConfirmations = 3
+)
+
+var _ clients.LndClient = (*LndClient)(nil)
+
+// LndClient in-memory stand-in for local LND node
+type LndClient struct {
+	mu sync.Mutex
+
+	// Locked wallet state
+	Locked bool
+	// Password to unlock the wallet
+	Password string
+	// IdentityPubKey of the local node
+	IdentityPubKey string
+	// WalletBalance confirmed on-chain balance
+	WalletBalance decimal.Decimal
+	// UnconfirmedBalance on-chain
+	UnconfirmedBalance decimal.Decimal
+	// DepositAddress returned by FundingAddress()
+	DepositAddress string
+	// PeerAddresses (pubkey@host) the node connected to
+	PeerAddresses []string
+	// ChannelList of open and pending channels
+	ChannelList []*clients.ChannelStatus
+	// ClosedChannelList of closed channels
+	ClosedChannelList []*clients.ClosedChannel
+	// PaymentList of sent payments
+	PaymentList []clients.Payment
+	// TransactionList of on-chain wallet transactions
+	TransactionList []clients.Transaction
+	// IncomingTransactions sent to transaction subscribers, confirmed ones are credited to WalletBalance
+	IncomingTransactions []clients.Transaction
+	// InvoiceList of invoices added to the local node
+	InvoiceList []*clients.LocalInvoice
+	// SettleInvoices on WaitInvoice as if they were paid by remote node
+	SettleInvoices bool
+	// OpenParams of opened channels by their channel points
+	OpenParams map[string]clients.OpenChannelParams
+	// CloseParams of closed channels by their channel points
+	CloseParams map[string]clients.CloseChannelParams
+	// ConfirmOnOpen channels right after they are pending as if funding transaction was mined
+	ConfirmOnOpen bool
+	// ConfirmOnClose channels right after they are pending close as if closing transaction was mined
+	ConfirmOnClose bool
+	// HoldClose of channels sending no updates as if closing transaction was never broadcast
+	HoldClose bool
+	// Errors to be returned by methods, keyed by method name
+	Errors map[string]error
+
+	lastChanID uint64
+	lastTxID   int
+}
+
+// NewLndClient constructor
+func NewLndClient() *LndClient {
+	return &LndClient{
+		IdentityPubKey: LocalPubKey,
+		DepositAddress: "bcrt1qfakedepositaddress",
+		Errors:         map[string]error{},
+	}
+}
+
+// Factory returns constructor suitable for commands.ClientFactory
+func (c *LndClient) Factory() func(*cli.Context, bool) (clients.LndClient, error) {
+	return func(_ *cli.Context, unlocked bool) (clients.LndClient, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if unlocked && c.Locked {
+			return nil, &clients.UnlockError{Err: errors.New("wallet is locked")}
+		}
+		return c, nil
+	}
+}
+
+// ConfirmChannels moves pending open channels to active state
+func (c *LndClient) ConfirmChannels() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.ChannelList {
+		if ch.Status == "pending_open" {
+			c.confirmChannel(ch)
+		}
+	}
+}
+
+// confirmChannel assigns id to pending open channel and moves it to active state,
+// its funding transaction gets FundingConfirmations
+func (c *LndClient) confirmChannel(ch *clients.ChannelStatus) {
+	txid := strings.Split(ch.ChannelPoint, ":")[0]
+	for i := range c.TransactionList {
+		if c.TransactionList[i].TxID == txid {
+			c.TransactionList[i].NumConfirmations = FundingConfirmations
+		}
+	}
+	c.lastChanID++
+	ch.ID = c.lastChanID
+	ch.ShortChannelID = fmt.Sprintf("%dx%dx%d", ch.ID>>40, ch.ID>>16&0xFFFFFF, ch.ID&0xFFFF)
+	ch.Status = "active"
+}
+
+// Unlock local node wallet to bring it online
+func (c *LndClient) Unlock(password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Unlock"]; err != nil {
+		return err
+	}
+	if !c.Locked {
+		return errors.New("wallet already unlocked")
+	}
+	if password != c.Password {
+		return errors.New("invalid passphrase for master public key")
+	}
+	c.Locked = false
+	return nil
+}
+
+// Status of the local LND node
+func (c *LndClient) Status() (*lnrpc.GetInfoResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Status"]; err != nil {
+		return nil, err
+	}
+	info := &lnrpc.GetInfoResponse{
+		IdentityPubkey: c.IdentityPubKey,
+		NumPeers:       uint32(len(c.PeerAddresses)),
+		SyncedToChain:  true,
+	}
+	for _, ch := range c.ChannelList {
+		switch ch.Status {
+		case "active":
+			info.NumActiveChannels++
+		case "inactive":
+			info.NumInactiveChannels++
+		case "pending_open", "pending_closing", "pending_force_closing", "waiting_close":
+			info.NumPendingChannels++
+		}
+	}
+	return info, nil
+}
+
+// NodePubKey for local node
+func (c *LndClient) NodePubKey() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["NodePubKey"]; err != nil {
+		return "", err
+	}
+	return c.IdentityPubKey, nil
+}
+
+// Peers the local node connected to
+func (c *LndClient) Peers() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Peers"]; err != nil {
+		return nil, err
+	}
+	return append([]string{}, c.PeerAddresses...), nil
+}
+
+// Connect local node to remote LND node
+func (c *LndClient) Connect(address string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Connect"]; err != nil {
+		return err
+	}
+	if _, err := splitAddress(address); err != nil {
+		return err
+	}
+	for _, p := range c.PeerAddresses {
+		if p == address {
+			return fmt.Errorf("already connected to peer: %s", address)
+		}
+	}
+	c.PeerAddresses = append(c.PeerAddresses, address)
+	return nil
+}
+
+// Disconnect local node from remote LND node
+func (c *LndClient) Disconnect(address string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Disconnect"]; err != nil {
+		return err
+	}
+	pubKey, err := splitAddress(address)
+	if err != nil {
+		return err
+	}
+	for i, p := range c.PeerAddresses {
+		if strings.HasPrefix(p, pubKey+"@") {
+			c.PeerAddresses = append(c.PeerAddresses[:i], c.PeerAddresses[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("peer %s is not connected", pubKey)
+}
+
+// Balance in BTC available on the local LND wallet
+func (c *LndClient) Balance() (decimal.Decimal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Balance"]; err != nil {
+		return decimal.Zero, err
+	}
+	return c.WalletBalance, nil
+}
+
+// DetailedBalance of the wallet and channels, local balances of force closing
+// and waiting close channels are accounted in limbo
+func (c *LndClient) DetailedBalance() (*clients.BalanceDetails, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["DetailedBalance"]; err != nil {
+		return nil, err
+	}
+	res := &clients.BalanceDetails{
+		OnChainConfirmed:   c.WalletBalance,
+		OnChainUnconfirmed: c.UnconfirmedBalance,
+	}
+	for _, ch := range c.ChannelList {
+		switch ch.Status {
+		case "active", "inactive":
+			res.ChannelLocal = res.ChannelLocal.Add(ch.LocalBalance)
+			res.ChannelRemote = res.ChannelRemote.Add(ch.RemoteBalance)
+		case "pending_open":
+			res.PendingOpen = res.PendingOpen.Add(ch.LocalBalance)
+		case "pending_closing":
+			res.PendingClose = res.PendingClose.Add(ch.LocalBalance)
+		default:
+			res.Limbo = res.Limbo.Add(ch.LocalBalance)
+		}
+	}
+	res.Total = res.OnChainConfirmed.Add(res.OnChainUnconfirmed).Add(res.ChannelLocal).
+		Add(res.PendingOpen).Add(res.PendingClose).Add(res.Limbo)
+	return res, nil
+}
+
+// FundingAddress for the local LND wallet
+func (c *LndClient) FundingAddress() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["FundingAddress"]; err != nil {
+		return "", err
+	}
+	return c.DepositAddress, nil
+}
+
+// OpenChannel to specified node and commit specified amount to it
+func (c *LndClient) OpenChannel(address string, amount decimal.Decimal, params clients.OpenChannelParams, out chan *clients.OpenChannelResult, done chan struct{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["OpenChannel"]; err != nil {
+		return err
+	}
+	pubKey, err := splitAddress(address)
+	if err != nil {
+		return err
+	}
+	connected := false
+	for _, p := range c.PeerAddresses {
+		if strings.HasPrefix(p, pubKey+"@") {
+			connected = true
+			break
+		}
+	}
+	if !connected {
+		return fmt.Errorf("peer %s is not online", pubKey)
+	}
+	if c.WalletBalance.LessThan(amount) {
+		return errors.New("not enough witness outputs to create funding transaction")
+	}
+	if !params.PushAmount.LessThan(amount) {
+		return errors.New("amount pushed to remote peer for initial state must be below the local funding amount")
+	}
+	c.WalletBalance = c.WalletBalance.Sub(amount)
+	txid := c.nextTxID()
+	c.TransactionList = append(c.TransactionList, clients.Transaction{TxID: txid, Amount: amount.Neg(), Timestamp: time.Now()})
+	ch := &clients.ChannelStatus{
+		Node:          pubKey,
+		ChannelPoint:  fmt.Sprintf("%s:0", txid),
+		Status:        "pending_open",
+		Capacity:      amount,
+		LocalBalance:  amount.Sub(params.PushAmount),
+		RemoteBalance: params.PushAmount,
+	}
+	c.ChannelList = append(c.ChannelList, ch)
+	if c.OpenParams == nil {
+		c.OpenParams = map[string]clients.OpenChannelParams{}
+	}
+	c.OpenParams[ch.ChannelPoint] = params
+	updates := []*clients.OpenChannelResult{{ChannelStatus: *ch}}
+	if c.ConfirmOnOpen {
+		c.confirmChannel(ch)
+		updates = append(updates, &clients.OpenChannelResult{ChannelStatus: *ch})
+	}
+	go func() {
+		for _, u := range updates {
+			select {
+			case <-done:
+				return
+			case out <- u:
+			}
+		}
+	}()
+	return nil
+}
+
+// Channels list
+func (c *LndClient) Channels() ([]*clients.ChannelStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Channels"]; err != nil {
+		return nil, err
+	}
+	res := []*clients.ChannelStatus{}
+	for _, ch := range c.ChannelList {
+		s := *ch
+		res = append(res, &s)
+	}
+	return res, nil
+}
+
+// ActiveChannels list
+func (c *LndClient) ActiveChannels() ([]*clients.ChannelStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["ActiveChannels"]; err != nil {
+		return nil, err
+	}
+	res := []*clients.ChannelStatus{}
+	for _, ch := range c.ChannelList {
+		if ch.Status == "active" {
+			s := *ch
+			res = append(res, &s)
+		}
+	}
+	return res, nil
+}
+
+// ClosedChannels list
+func (c *LndClient) ClosedChannels(offset, limit int) ([]*clients.ClosedChannel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["ClosedChannels"]; err != nil {
+		return nil, err
+	}
+	closed := append([]*clients.ClosedChannel{}, c.ClosedChannelList...)
+	sort.Slice(closed, func(i, j int) bool { return closed[i].CloseHeight > closed[j].CloseHeight })
+	first, last := page(len(closed), offset, limit)
+	return closed[first:last], nil
+}
+
+// CloseChannel with specified channel point.
+// Pending and close updates are sent to out until the channel is closed or done is closed
+func (c *LndClient) CloseChannel(chanID uint64, chanPoint string, params clients.CloseChannelParams, out chan *clients.CloseChannelResult, done chan struct{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["CloseChannel"]; err != nil {
+		return err
+	}
+	ch := c.findChannel(chanID, chanPoint)
+	if ch == nil {
+		return fmt.Errorf("channel not found")
+	}
+	if c.CloseParams == nil {
+		c.CloseParams = map[string]clients.CloseChannelParams{}
+	}
+	c.CloseParams[ch.ChannelPoint] = params
+	ch.ClosingTxid = c.nextTxID()
+	ch.Status = "waiting_close"
+	if params.Force {
+		ch.Status = "pending_force_closing"
+	}
+	updates := []*clients.CloseChannelResult{{ChannelStatus: *ch}}
+	if c.HoldClose {
+		updates = nil
+	}
+	if c.ConfirmOnClose {
+		c.confirmClose(ch, params.Force)
+		updates = append(updates, &clients.CloseChannelResult{ChannelStatus: *ch})
+	}
+	go func() {
+		for _, u := range updates {
+			select {
+			case <-done:
+				return
+			case out <- u:
+			}
+		}
+	}()
+	return nil
+}
+
+// confirmClose moves closing channel to closed list settling its local balance to the wallet,
+// local balance of force closed channel is time-locked instead, must be called under lock
+func (c *LndClient) confirmClose(ch *clients.ChannelStatus, force bool) {
+	height := uint32(0)
+	for _, cc := range c.ClosedChannelList {
+		if cc.CloseHeight > height {
+			height = cc.CloseHeight
+		}
+	}
+	closed := &clients.ClosedChannel{
+		ID:           ch.ID,
+		Node:         ch.Node,
+		ChannelPoint: ch.ChannelPoint,
+		Capacity:     ch.Capacity,
+		ClosingTxid:  ch.ClosingTxid,
+		CloseHeight:  height + 1,
+		CloseType:    "COOPERATIVE_CLOSE",
+	}
+	if force {
+		closed.TimeLockedBalance = ch.LocalBalance
+		closed.CloseType = "LOCAL_FORCE_CLOSE"
+	} else {
+		closed.SettledBalance = ch.LocalBalance
+		c.WalletBalance = c.WalletBalance.Add(ch.LocalBalance)
+	}
+	c.ClosedChannelList = append(c.ClosedChannelList, closed)
+	for i, cur := range c.ChannelList {
+		if cur == ch {
+			c.ChannelList = append(c.ChannelList[:i], c.ChannelList[i+1:]...)
+			break
+		}
+	}
+	ch.Status = "closed"
+}
+
+// SendPayment by specified payment request on specified amount and wait for its final state
+func (c *LndClient) SendPayment(paymentReq string, amount decimal.Decimal, chanID uint64, timeout time.Duration) (*clients.PaymentResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["SendPayment"]; err != nil {
+		return nil, err
+	}
+	hash := paymentHash(paymentReq)
+	ch := c.findChannel(chanID, "")
+	if ch == nil || ch.Status != "active" {
+		return &clients.PaymentResult{PaymentHash: hash, Status: clients.PaymentFailed, Amount: amount,
+			Error: "unable to find a path to destination"}, nil
+	}
+	if ch.LocalBalance.LessThan(amount) {
+		return &clients.PaymentResult{PaymentHash: hash, Status: clients.PaymentFailed, Amount: amount,
+			Error: "insufficient local balance"}, nil
+	}
+	ch.LocalBalance = ch.LocalBalance.Sub(amount)
+	ch.RemoteBalance = ch.RemoteBalance.Add(amount)
+	preimage := sha256.Sum256([]byte(hash))
+	p := clients.Payment{
+		PaymentHash: hash,
+		Node:        ch.Node,
+		Timestamp:   time.Now(),
+		Amount:      amount,
+		Status:      clients.PaymentSucceeded,
+		Preimage:    hex.EncodeToString(preimage[:]),
+	}
+	c.PaymentList = append(c.PaymentList, p)
+	return &clients.PaymentResult{
+		PaymentHash: hash,
+		Status:      p.Status,
+		Amount:      amount,
+		Preimage:    p.Preimage,
+		Route:       []clients.RouteHop{{ChanID: ch.ID, PubKey: ch.Node, Forward: amount}},
+	}, nil
+}
+
+// PaymentHash of specified payment request
+func (c *LndClient) PaymentHash(paymentReq string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["PaymentHash"]; err != nil {
+		return "", err
+	}
+	return paymentHash(paymentReq), nil
+}
+
+// FindPayment including incomplete ones by its hash, nil if not found
+func (c *LndClient) FindPayment(hash string) (*clients.Payment, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["FindPayment"]; err != nil {
+		return nil, err
+	}
+	for _, p := range c.PaymentList {
+		if p.PaymentHash == hash {
+			res := p
+			return &res, nil
+		}
+	}
+	return nil, nil
+}
+
+// Payments list
+func (c *LndClient) Payments(offset, limit int) ([]clients.Payment, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Payments"]; err != nil {
+		return nil, err
+	}
+	payments := append([]clients.Payment{}, c.PaymentList...)
+	sort.Slice(payments, func(i, j int) bool { return payments[i].Timestamp.After(payments[j].Timestamp) })
+	first, last := page(len(payments), offset, limit)
+	return payments[first:last], nil
+}
+
+// AddInvoice on specified amount to be paid to the local node
+func (c *LndClient) AddInvoice(amount decimal.Decimal, memo string) (*clients.LocalInvoice, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["AddInvoice"]; err != nil {
+		return nil, err
+	}
+	req := fmt.Sprintf("lnfakelocal%d", len(c.InvoiceList)+1)
+	inv := &clients.LocalInvoice{
+		PaymentHash:    paymentHash(req),
+		PaymentRequest: req,
+		Amount:         amount,
+		Status:         clients.InvoiceStatusOpen,
+	}
+	c.InvoiceList = append(c.InvoiceList, inv)
+	res := *inv
+	return &res, nil
+}
+
+// WaitInvoice returns invoice state at once, settling it if SettleInvoices is set
+func (c *LndClient) WaitInvoice(hash string, timeout time.Duration) (*clients.LocalInvoice, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["WaitInvoice"]; err != nil {
+		return nil, err
+	}
+	for _, inv := range c.InvoiceList {
+		if inv.PaymentHash != hash {
+			continue
+		}
+		if c.SettleInvoices && inv.Status == clients.InvoiceStatusOpen {
+			now := time.Now()
+			inv.Status = clients.InvoiceStatusSettled
+			inv.AmountPaid = inv.Amount
+			inv.SettledAt = &now
+		}
+		res := *inv
+		return &res, nil
+	}
+	return nil, errors.New("unable to locate invoice")
+}
+
+// Transactions list of the wallet
+func (c *LndClient) Transactions(offset, limit int) ([]clients.Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Transactions"]; err != nil {
+		return nil, err
+	}
+	txs := append([]clients.Transaction{}, c.TransactionList...)
+	sort.Slice(txs, func(i, j int) bool { return txs[i].Timestamp.After(txs[j].Timestamp) })
+	first, last := page(len(txs), offset, limit)
+	return txs[first:last], nil
+}
+
+// Transaction of the wallet by its id, nil if not found
+func (c *LndClient) Transaction(txid string) (*clients.Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Transaction"]; err != nil {
+		return nil, err
+	}
+	for _, t := range c.TransactionList {
+		if t.TxID == txid {
+			tx := t
+			return &tx, nil
+		}
+	}
+	return nil, nil
+}
+
+// SubscribeTransactions sends IncomingTransactions to out until done is closed,
+// confirmed ones are credited to WalletBalance and appended to TransactionList
+func (c *LndClient) SubscribeTransactions(out chan *clients.TransactionUpdate, done chan struct{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["SubscribeTransactions"]; err != nil {
+		return err
+	}
+	incoming := c.IncomingTransactions
+	c.IncomingTransactions = nil
+	go func() {
+		for i := range incoming {
+			tx := incoming[i]
+			if tx.NumConfirmations > 0 {
+				c.mu.Lock()
+				c.WalletBalance = c.WalletBalance.Add(tx.Amount)
+				c.TransactionList = append(c.TransactionList, tx)
+				c.mu.Unlock()
+			}
+			select {
+			case <-done:
+				return
+			case out <- &clients.TransactionUpdate{Transaction: &tx}:
+			}
+		}
+	}()
+	return nil
+}
+
+// ConfirmedBalance of the wallet, every confirmed output counts regardless of minConfs
+func (c *LndClient) ConfirmedBalance(minConfs int32) (decimal.Decimal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["ConfirmedBalance"]; err != nil {
+		return decimal.Zero, err
+	}
+	return c.WalletBalance, nil
+}
+
+// Close gRPC connection
+func (c *LndClient) Close() error {
+	return nil
+}
+
+// findChannel by id or channel point, must be called under lock
+func (c *LndClient) findChannel(chanID uint64, chanPoint string) *clients.ChannelStatus {
+	for _, ch := range c.ChannelList {
+		if (chanID != 0 && ch.ID == chanID) || (chanPoint != "" && ch.ChannelPoint == chanPoint) {
+			return ch
+		}
+	}
+	return nil
+}
+
+// nextTxID generates fake transaction id, must be called under lock
+func (c *LndClient) nextTxID() string {
+	c.lastTxID++
+	return fmt.Sprintf("%064x", c.lastTxID)
+}
+
+// paymentHash of fake payment request
+func paymentHash(paymentReq string) string {
+	hash := sha256.Sum256([]byte(paymentReq))
+	return hex.EncodeToString(hash[:])
+}
+
+// splitAddress validates pubkey@host address and returns its pubkey
+func splitAddress(address string) (string, error) {
+	addrParts := strings.Split(address, "@")
+	if len(addrParts) != 2 || addrParts[0] == "" || addrParts[1] == "" {
+		return "", fmt.Errorf("Invalid address format: %s", address)
+	}
+	return addrParts[0], nil
+}
+
+// page bounds for offset and limit over n items
+func page(n, offset, limit int) (int, int) {
+	if offset >= n {
+		return n, n
+	}
+	last := offset + limit
+	if last > n {
+		last = n
+	}
+	return offset, last
+}
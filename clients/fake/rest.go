@@ -0,0 +1,290 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+// IssuedInvoice record of an invoice issued by fake Xena API
+type IssuedInvoice struct {
+	AccountID  int64
+	ExternalID string
+	Invoice    *clients.Invoice
+	// Status of the invoice, open if empty
+	Status string
+	// Amount paid to the invoice
+	Amount decimal.Decimal
+}
+
+// WithdrawalRequest record of a withdrawal requested from fake Xena API
+type WithdrawalRequest struct {
+	PaymentRequest string
+	ChanPoint      string
+	Withdrawal     *clients.Withdrawal
+}
+
+var _ clients.RestClient = (*RestClient)(nil)
+
+// RestClient in-memory stand-in for Xena dAccs API
+type RestClient struct {
+	mu sync.Mutex
+
+	// NodeList of Xena lnd nodes
+	NodeList []*clients.Node
+	// APILimits returned by Limits()
+	APILimits clients.Limits
+	// RegisteredPubKeys of local lnd nodes
+	RegisteredPubKeys []string
+	// IssuedInvoices in order of issuance
+	IssuedInvoices []*IssuedInvoice
+	// Withdrawals in order of request
+	Withdrawals []*WithdrawalRequest
+	// AccountList of Xena user
+	AccountList []*clients.Account
+	// Balances by account id
+	Balances map[int64]*clients.AccountBalance
+	// DepositList in order of creation
+	DepositList []*clients.Deposit
+	// MarkPrices by symbol
+	MarkPrices map[string]decimal.Decimal
+	// Errors to be returned by methods, keyed by method name
+	Errors map[string]error
+}
+
+// NewRestClient constructor
+func NewRestClient() *RestClient {
+	return &RestClient{
+		NodeList: []*clients.Node{
+			{ID: "xena-1", Address: RemotePubKey + "@127.0.0.1:9735"},
+		},
+		APILimits: clients.Limits{
+			MinChannelCapacity:       decimal.New(1, -3),
+			MinPaymentAmount:         decimal.New(1, -5),
+			ChannelReserveMultiplier: decimal.New(1, 0),
+		},
+		AccountList: []*clients.Account{
+			{ID: 1, Kind: "margin", Currency: "BTC"},
+		},
+		Balances: map[int64]*clients.AccountBalance{},
+		MarkPrices: map[string]decimal.Decimal{
+			"XBTUSD": decimal.New(10000, 0),
+		},
+		Errors: map[string]error{},
+	}
+}
+
+// Factory returns constructor suitable for commands.ClientFactory
+func (c *RestClient) Factory() func(*cli.Context) (clients.RestClient, error) {
+	return func(*cli.Context) (clients.RestClient, error) {
+		return c, nil
+	}
+}
+
+// RegisterNode registers local lnd node in association with Xena user
+func (c *RestClient) RegisterNode(pubKey string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["RegisterNode"]; err != nil {
+		return err
+	}
+	for _, k := range c.RegisteredPubKeys {
+		if k == pubKey {
+			return nil
+		}
+	}
+	c.RegisteredPubKeys = append(c.RegisteredPubKeys, pubKey)
+	return nil
+}
+
+// RemoteNodes list Xena lnd nodes to connect to
+func (c *RestClient) RemoteNodes() ([]*clients.Node, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["RemoteNodes"]; err != nil {
+		return nil, err
+	}
+	res := make([]*clients.Node, 0, len(c.NodeList))
+	for _, n := range c.NodeList {
+		node := *n
+		res = append(res, &node)
+	}
+	return res, nil
+}
+
+// RemoteAddresses of Xena lnd nodes to connect to
+func (c *RestClient) RemoteAddresses() ([]string, error) {
+	nodes, err := c.RemoteNodes()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, n.Address)
+	}
+	return res, nil
+}
+
+// IssueInvoices to pay via specified channels, the same invoices are returned for repeated externalID
+func (c *RestClient) IssueInvoices(accountID int64, externalID string, chanPoints []string) ([]*clients.Invoice, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["IssueInvoices"]; err != nil {
+		return nil, err
+	}
+	res := []*clients.Invoice{}
+	for _, i := range c.IssuedInvoices {
+		if i.AccountID == accountID && i.ExternalID == externalID {
+			res = append(res, i.Invoice)
+		}
+	}
+	if len(res) > 0 {
+		return res, nil
+	}
+	if len(c.NodeList) == 0 {
+		return nil, fmt.Errorf("no nodes available")
+	}
+	for _, cp := range chanPoints {
+		inv := &clients.Invoice{
+			NodeID:         c.NodeList[0].ID,
+			PaymentRequest: fmt.Sprintf("lnfake%d", len(c.IssuedInvoices)+1),
+			ChanPoint:      cp,
+		}
+		c.IssuedInvoices = append(c.IssuedInvoices, &IssuedInvoice{AccountID: accountID, ExternalID: externalID, Invoice: inv})
+		res = append(res, inv)
+	}
+	return res, nil
+}
+
+// Limits returns daccs limits
+func (c *RestClient) Limits() (*clients.Limits, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Limits"]; err != nil {
+		return nil, err
+	}
+	limits := c.APILimits
+	return &limits, nil
+}
+
+// InvoiceStatus of the issued invoice by its payment hash
+func (c *RestClient) InvoiceStatus(hash string) (*clients.InvoiceStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["InvoiceStatus"]; err != nil {
+		return nil, err
+	}
+	for _, i := range c.IssuedInvoices {
+		if paymentHash(i.Invoice.PaymentRequest) != hash {
+			continue
+		}
+		status := i.Status
+		if status == "" {
+			status = clients.InvoiceOpen
+		}
+		return &clients.InvoiceStatus{
+			PaymentHash: hash,
+			AccountID:   i.AccountID,
+			ExternalID:  i.ExternalID,
+			Status:      status,
+			Amount:      i.Amount,
+		}, nil
+	}
+	return nil, &clients.APIError{StatusCode: 404, Method: "GET", Path: "invoices/" + hash, Message: "invoice not found"}
+}
+
+// RequestWithdrawal from account, the same withdrawal is returned for repeated payment request
+func (c *RestClient) RequestWithdrawal(accountID int64, paymentRequest, chanPoint string) (*clients.Withdrawal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["RequestWithdrawal"]; err != nil {
+		return nil, err
+	}
+	for _, w := range c.Withdrawals {
+		if w.PaymentRequest == paymentRequest {
+			return w.Withdrawal, nil
+		}
+	}
+	w := &WithdrawalRequest{
+		PaymentRequest: paymentRequest,
+		ChanPoint:      chanPoint,
+		Withdrawal: &clients.Withdrawal{
+			ID:          fmt.Sprintf("w%d", len(c.Withdrawals)+1),
+			AccountID:   accountID,
+			PaymentHash: paymentHash(paymentRequest),
+			Status:      "pending",
+		},
+	}
+	c.Withdrawals = append(c.Withdrawals, w)
+	return w.Withdrawal, nil
+}
+
+// Accounts of Xena user
+func (c *RestClient) Accounts() ([]*clients.Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Accounts"]; err != nil {
+		return nil, err
+	}
+	res := make([]*clients.Account, 0, len(c.AccountList))
+	for _, a := range c.AccountList {
+		account := *a
+		res = append(res, &account)
+	}
+	return res, nil
+}
+
+// Balance of specified account, zero for known accounts without balance set
+func (c *RestClient) Balance(accountID int64) (*clients.AccountBalance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Balance"]; err != nil {
+		return nil, err
+	}
+	if b, ok := c.Balances[accountID]; ok {
+		res := *b
+		return &res, nil
+	}
+	for _, a := range c.AccountList {
+		if a.ID == accountID {
+			return &clients.AccountBalance{AccountID: accountID, Currency: a.Currency}, nil
+		}
+	}
+	return nil, &clients.APIError{StatusCode: 404, Method: "GET", Path: fmt.Sprintf("accounts/%d/balance", accountID), Message: "account not found"}
+}
+
+// Deposits to specified account, last ones first
+func (c *RestClient) Deposits(accountID int64, offset, limit int) ([]*clients.Deposit, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["Deposits"]; err != nil {
+		return nil, err
+	}
+	deposits := []*clients.Deposit{}
+	for i := len(c.DepositList) - 1; i >= 0; i-- {
+		if c.DepositList[i].AccountID == accountID {
+			d := *c.DepositList[i]
+			deposits = append(deposits, &d)
+		}
+	}
+	first, last := page(len(deposits), offset, limit)
+	return deposits[first:last], nil
+}
+
+// MarkPrice of specified symbol
+func (c *RestClient) MarkPrice(symbol string) (*clients.MarkPrice, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Errors["MarkPrice"]; err != nil {
+		return nil, err
+	}
+	price, ok := c.MarkPrices[symbol]
+	if !ok {
+		return nil, &clients.APIError{StatusCode: 404, Method: "GET", Path: "market-data/mark-price/" + symbol, Message: "symbol not found"}
+	}
+	return &clients.MarkPrice{Symbol: symbol, Price: price, Timestamp: time.Now().UTC()}, nil
+}
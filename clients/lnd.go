@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -28,7 +30,8 @@ import (
 const (
 	defaultGRPCTimeout = 5 * time.Second
 	defaultWaitUnlock  = 5 * time.Second
-	defaultCSVDelay    = 288 // ~48 hours
+	// DefaultCSVDelay of remote party funds in blocks, ~48 hours
+	DefaultCSVDelay = 288
 
 	recreateAfterUnlockAttemptsCount = 5
 	recreateAfterUnlockInterval      = time.Second
@@ -36,15 +39,19 @@ const (
 
 // ChannelStatus descriptor
 type ChannelStatus struct {
-	ID            uint64          `json:"id,omitempty"`
-	Node          string          `json:"node"`
-	ChannelPoint  string          `json:"channel_point"`
-	Status        string          `json:"status"`
-	Capacity      decimal.Decimal `json:"capacity"`
-	LocalBalance  decimal.Decimal `json:"local_balance"`
-	RemoteBalance decimal.Decimal `json:"remote_balance"`
-	ClosingTxid   string          `json:"closing_txid,omitempty"`
-	LocalReserved decimal.Decimal `json:"-"`
+	ID             uint64          `json:"id,omitempty"`
+	ShortChannelID string          `json:"short_channel_id,omitempty"`
+	Node           string          `json:"node"`
+	ChannelPoint   string          `json:"channel_point"`
+	Status         string          `json:"status"`
+	Capacity       decimal.Decimal `json:"capacity" unit:"btc"`
+	LocalBalance   decimal.Decimal `json:"local_balance" unit:"btc"`
+	RemoteBalance  decimal.Decimal `json:"remote_balance" unit:"btc"`
+	ClosingTxid    string          `json:"closing_txid,omitempty"`
+	LocalReserved  decimal.Decimal `json:"-"`
+	RemoteReserved decimal.Decimal `json:"-"`
+	// CsvDelay of local funds in blocks on force close, zero if unknown
+	CsvDelay uint32 `json:"-"`
 }
 
 // ClosedChannel struct
@@ -52,38 +59,140 @@ type ClosedChannel struct {
 	ID                uint64          `json:"id,omitempty"`
 	Node              string          `json:"node"`
 	ChannelPoint      string          `json:"channel_point"`
-	Capacity          decimal.Decimal `json:"capacity"`
-	SettledBalance    decimal.Decimal `json:"settled_balance"`
-	TimeLockedBalance decimal.Decimal `json:"time_locked_balance"`
+	Capacity          decimal.Decimal `json:"capacity" unit:"btc"`
+	SettledBalance    decimal.Decimal `json:"settled_balance" unit:"btc"`
+	TimeLockedBalance decimal.Decimal `json:"time_locked_balance" unit:"btc"`
 	ClosingTxid       string          `json:"closing_txid,omitempty"`
 	CloseHeight       uint32          `json:"close_height"`
 	CloseType         string          `json:"close_type"`
 }
 
+// OpenChannelParams of channel funding and commitment, zero fee settings fall back to lnd defaults
+type OpenChannelParams struct {
+	// SatPerByte fee rate of funding transaction
+	SatPerByte int64
+	// TargetConf in blocks to estimate funding transaction fee rate for
+	TargetConf int32
+	// PushAmount to the remote party
+	PushAmount decimal.Decimal
+	// MinHtlc accepted by the local node
+	MinHtlc decimal.Decimal
+	// RemoteCsvDelay of remote party funds in blocks, DefaultCSVDelay if zero
+	RemoteCsvDelay uint32
+	// Private channel is not announced to the network
+	Private bool
+	// MinConfs of funding transaction inputs, unconfirmed ones are spent if zero
+	MinConfs int32
+}
+
+// CloseChannelParams of cooperative or force close, zero fee settings fall back to lnd defaults.
+// Local funds are always paid to the LND wallet, linked lnrpc has no close delivery address
+type CloseChannelParams struct {
+	// Force close unilaterally, funds are time-locked for channel CSV delay
+	Force bool
+	// SatPerByte fee rate of cooperative closing transaction
+	SatPerByte int64
+	// TargetConf in blocks to estimate cooperative closing transaction fee rate for
+	TargetConf int32
+}
+
 // OpenChannelResult description
 type OpenChannelResult struct {
 	ChannelStatus
 	Error error
 }
 
+// CloseChannelResult description
+type CloseChannelResult struct {
+	ChannelStatus
+	Error error
+}
+
+// Payment statuses
+const (
+	PaymentInFlight  = "in_flight"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+)
+
 // Payment description
 type Payment struct {
-	Node      string          `json:"node"`
-	Timestamp time.Time       `json:"timestamp"`
-	Amount    decimal.Decimal `json:"amount"`
+	PaymentHash string          `json:"payment_hash"`
+	Node        string          `json:"node"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Amount      decimal.Decimal `json:"amount" unit:"btc"`
+	Fee         decimal.Decimal `json:"fee" unit:"btc"`
+	Status      string          `json:"status"`
+	Preimage    string          `json:"preimage,omitempty"`
+}
+
+// PaymentResult description
+type PaymentResult struct {
+	PaymentHash string          `json:"payment_hash"`
+	Status      string          `json:"status"`
+	Amount      decimal.Decimal `json:"amount" unit:"btc"`
+	Fee         decimal.Decimal `json:"fee" unit:"btc"`
+	Preimage    string          `json:"preimage,omitempty"`
+	Route       []RouteHop      `json:"route,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// RouteHop description
+type RouteHop struct {
+	ChanID  uint64          `json:"chan_id"`
+	PubKey  string          `json:"pub_key"`
+	Forward decimal.Decimal `json:"amount_to_forward" unit:"btc"`
+	Fee     decimal.Decimal `json:"fee" unit:"btc"`
+	Expiry  uint32          `json:"expiry"`
+}
+
+// BalanceDetails of the local node wallet and channels
+type BalanceDetails struct {
+	OnChainConfirmed   decimal.Decimal `json:"onchain_confirmed" unit:"btc"`
+	OnChainUnconfirmed decimal.Decimal `json:"onchain_unconfirmed" unit:"btc"`
+	ChannelLocal       decimal.Decimal `json:"channel_local" unit:"btc"`
+	ChannelRemote      decimal.Decimal `json:"channel_remote" unit:"btc"`
+	PendingOpen        decimal.Decimal `json:"pending_open" unit:"btc"`
+	PendingClose       decimal.Decimal `json:"pending_close" unit:"btc"`
+	Limbo              decimal.Decimal `json:"limbo" unit:"btc"`
+	// Total of the local node funds, remote balance is not included
+	Total decimal.Decimal `json:"total" unit:"btc"`
+}
+
+// Invoice statuses
+const (
+	InvoiceStatusOpen     = "open"
+	InvoiceStatusSettled  = "settled"
+	InvoiceStatusCanceled = "canceled"
+)
+
+// LocalInvoice of the local lnd node
+type LocalInvoice struct {
+	PaymentHash    string          `json:"payment_hash"`
+	PaymentRequest string          `json:"payment_request"`
+	Amount         decimal.Decimal `json:"amount" unit:"btc"`
+	AmountPaid     decimal.Decimal `json:"amount_paid" unit:"btc"`
+	Status         string          `json:"status"`
+	SettledAt      *time.Time      `json:"settled_at,omitempty"`
 }
 
 // Transaction struct
 type Transaction struct {
 	TxID             string          `json:"txid"`
-	Amount           decimal.Decimal `json:"amount"`
+	Amount           decimal.Decimal `json:"amount" unit:"btc"`
 	NumConfirmations int32           `json:"num_confirmations"`
 	BlockHeight      int32           `json:"block_height"`
 	Timestamp        time.Time       `json:"timestamp"`
-	TotalFees        decimal.Decimal `json:"total_fees"`
+	TotalFees        decimal.Decimal `json:"total_fees" unit:"btc"`
 	DestAddresses    []string        `json:"dest_addresses"`
 }
 
+// TransactionUpdate of wallet transactions subscription
+type TransactionUpdate struct {
+	Transaction *Transaction
+	Error       error
+}
+
 // LndClient interface
 type LndClient interface {
 	// Unlock local node wallet to bring it online
@@ -100,28 +209,70 @@ type LndClient interface {
 	Disconnect(address string) error
 	// Balance in BTC available on the local LND wallet
 	Balance() (decimal.Decimal, error)
+	// DetailedBalance of the local LND wallet and channels
+	DetailedBalance() (*BalanceDetails, error)
+	// ConfirmedBalance in BTC of the local LND wallet outputs with at least minConfs confirmations
+	ConfirmedBalance(minConfs int32) (decimal.Decimal, error)
 	// FundingAddress for the local LND wallet
 	FundingAddress() (string, error)
-	// OpenChannel to specified node and commit specified amount to it
-	OpenChannel(address string, amount decimal.Decimal, out chan *OpenChannelResult) error
+	// OpenChannel to specified node and commit specified amount to it.
+	// Updates are sent to out until the channel is open or done is closed
+	OpenChannel(address string, amount decimal.Decimal, params OpenChannelParams, out chan *OpenChannelResult, done chan struct{}) error
 	// Channels list
 	Channels() ([]*ChannelStatus, error)
 	// ActiveChannels list
 	ActiveChannels() ([]*ChannelStatus, error)
 	// ClosedChannels list
 	ClosedChannels(offset, limit int) ([]*ClosedChannel, error)
-	// CloseChannel with specified channel point
-	CloseChannel(chanID uint64, chanPoint string) (*ChannelStatus, error)
-	// SendPayment by specified payment request on specified amount
-	SendPayment(paymentReq string, amount decimal.Decimal, chanID uint64) error
+	// CloseChannel with specified channel point.
+	// Pending and close updates are sent to out until the channel is closed or done is closed,
+	// stream failure is sent as the last update
+	CloseChannel(chanID uint64, chanPoint string, params CloseChannelParams, out chan *CloseChannelResult, done chan struct{}) error
+	// SendPayment by specified payment request on specified amount and wait for its final state.
+	// If timeout elapses earlier the payment is reported in flight
+	SendPayment(paymentReq string, amount decimal.Decimal, chanID uint64, timeout time.Duration) (*PaymentResult, error)
+	// PaymentHash of specified payment request
+	PaymentHash(paymentReq string) (string, error)
+	// FindPayment including incomplete ones by its hash, nil if not found
+	FindPayment(paymentHash string) (*Payment, error)
 	// Payments list
 	Payments(offset, limit int) ([]Payment, error)
+	// AddInvoice on specified amount to be paid to the local node
+	AddInvoice(amount decimal.Decimal, memo string) (*LocalInvoice, error)
+	// WaitInvoice until it's settled or canceled, its current state is returned if timeout elapses earlier
+	WaitInvoice(paymentHash string, timeout time.Duration) (*LocalInvoice, error)
 	// Wallet transactions list
 	Transactions(offset, limit int) ([]Transaction, error)
+	// Transaction of the wallet by its id, nil if not found
+	Transaction(txid string) (*Transaction, error)
+	// SubscribeTransactions of the wallet sending their updates to out until done is closed.
+	// Subscription failure is sent as the last update
+	SubscribeTransactions(out chan *TransactionUpdate, done chan struct{}) error
 	// Close gRPC connection
 	Close() error
 }
 
+// ConnectionError of local LND node
+type ConnectionError struct {
+	Host string
+	Err  error
+}
+
+// Error implements error interface
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("Error %s on connecting to lnd %s", e.Err, e.Host)
+}
+
+// UnlockError of locked local LND node
+type UnlockError struct {
+	Err error
+}
+
+// Error implements error interface
+func (e *UnlockError) Error() string {
+	return fmt.Sprintf("Error %s on unlocking lnd node", e.Err)
+}
+
 // lndClient implementation
 type lndClient struct {
 	connection     *grpc.ClientConn
@@ -167,7 +318,7 @@ func NewLndClient(c *cli.Context, unlocked bool) (LndClient, error) {
 
 	conn, err := grpc.Dial(lndHost, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("Error %s on connecting to lnd %s", err, lndHost)
+		return nil, &ConnectionError{Host: lndHost, Err: err}
 	}
 
 	walletUnlocker := lnrpc.NewWalletUnlockerClient(conn)
@@ -181,26 +332,27 @@ func NewLndClient(c *cli.Context, unlocked bool) (LndClient, error) {
 		if err != nil {
 			s, ok := status.FromError(err)
 			if !ok {
-				return nil, fmt.Errorf("Error %s on connecting to lnd %s", err, lndHost)
+				return nil, &ConnectionError{Host: lndHost, Err: err}
 			}
 			if s.Code() != codes.Unimplemented {
-				return nil, fmt.Errorf("Error %s on connecting to lnd %s", err, lndHost)
+				return nil, &ConnectionError{Host: lndHost, Err: err}
 			}
 
-			fmt.Println("Local LND node is locked")
-			fmt.Printf("Input unlock password: ")
+			// Prompt goes to stderr to keep stdout clean for the response
+			fmt.Fprintln(os.Stderr, "Local LND node is locked")
+			fmt.Fprint(os.Stderr, "Input unlock password: ")
 			pwd, err := terminal.ReadPassword(int(syscall.Stdin))
+			fmt.Fprintln(os.Stderr)
 			if err != nil {
-				return nil, err
+				return nil, &UnlockError{Err: err}
 			}
-			fmt.Println()
 
 			ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
 			defer cancel()
 
 			_, err = walletUnlocker.UnlockWallet(ctx, &lnrpc.UnlockWalletRequest{WalletPassword: ([]byte)(pwd)})
 			if err != nil {
-				return nil, fmt.Errorf("Error %s on unlocking lnd node", err)
+				return nil, &UnlockError{Err: err}
 			}
 
 			// Wait until lnd rpc server is ready, recreate client and test with GetInfo()
@@ -210,7 +362,7 @@ func NewLndClient(c *cli.Context, unlocked bool) (LndClient, error) {
 				conn.Close()
 				conn, err = grpc.Dial(lndHost, opts...)
 				if err != nil {
-					return nil, fmt.Errorf("Error %s on connecting to lnd %s", err, lndHost)
+					return nil, &ConnectionError{Host: lndHost, Err: err}
 				}
 				client = lnrpc.NewLightningClient(conn)
 				ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
@@ -221,7 +373,7 @@ func NewLndClient(c *cli.Context, unlocked bool) (LndClient, error) {
 				}
 			}
 			if err != nil {
-				return nil, err
+				return nil, &ConnectionError{Host: lndHost, Err: err}
 			}
 		}
 	}
@@ -319,6 +471,67 @@ func (c *lndClient) Balance() (decimal.Decimal, error) {
 	return satoshiToBTC(bal.ConfirmedBalance), nil
 }
 
+// DetailedBalance of the local LND wallet and channels
+func (c *lndClient) DetailedBalance() (*BalanceDetails, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	wallet, err := c.client.WalletBalance(ctx, &lnrpc.WalletBalanceRequest{})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	channels, err := c.client.ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	pending, err := c.client.PendingChannels(ctx, &lnrpc.PendingChannelsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	var local, remote, pendingOpen, pendingClose int64
+	for _, ch := range channels.Channels {
+		local += ch.LocalBalance
+		remote += ch.RemoteBalance
+	}
+	for _, ch := range pending.PendingOpenChannels {
+		pendingOpen += ch.Channel.LocalBalance
+	}
+	// Balances of waiting close and force closing channels are accounted in limbo balance
+	for _, ch := range pending.PendingClosingChannels {
+		pendingClose += ch.Channel.LocalBalance
+	}
+	res := &BalanceDetails{
+		OnChainConfirmed:   satoshiToBTC(wallet.ConfirmedBalance),
+		OnChainUnconfirmed: satoshiToBTC(wallet.UnconfirmedBalance),
+		ChannelLocal:       satoshiToBTC(local),
+		ChannelRemote:      satoshiToBTC(remote),
+		PendingOpen:        satoshiToBTC(pendingOpen),
+		PendingClose:       satoshiToBTC(pendingClose),
+		Limbo:              satoshiToBTC(pending.TotalLimboBalance),
+	}
+	res.Total = satoshiToBTC(wallet.ConfirmedBalance + wallet.UnconfirmedBalance + local + pendingOpen + pendingClose + pending.TotalLimboBalance)
+	return res, nil
+}
+
+// ConfirmedBalance in BTC of the local LND wallet outputs with at least minConfs confirmations
+func (c *lndClient) ConfirmedBalance(minConfs int32) (decimal.Decimal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	resp, err := c.client.ListUnspent(ctx, &lnrpc.ListUnspentRequest{MinConfs: minConfs, MaxConfs: math.MaxInt32})
+	if err != nil {
+		return decimal.Zero, err
+	}
+	var sat int64
+	for _, u := range resp.Utxos {
+		sat += u.AmountSat
+	}
+	return satoshiToBTC(sat), nil
+}
+
 // FundingAddress for the local LND wallet
 func (c *lndClient) FundingAddress() (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
@@ -330,8 +543,10 @@ func (c *lndClient) FundingAddress() (string, error) {
 	return addr.Address, nil
 }
 
-// OpenChannel to specified node and commit specified amount to it
-func (c *lndClient) OpenChannel(address string, amount decimal.Decimal, out chan *OpenChannelResult) error {
+// OpenChannel to specified node and commit specified amount to it.
+// Pending and open updates are sent to out until the channel is open or done is closed,
+// stream failure is sent as the last update
+func (c *lndClient) OpenChannel(address string, amount decimal.Decimal, params OpenChannelParams, out chan *OpenChannelResult, done chan struct{}) error {
 	addrParts := strings.Split(address, "@")
 	if len(addrParts) != 2 || addrParts[0] == "" || addrParts[1] == "" {
 		return fmt.Errorf("Invalid address format: %s", address)
@@ -340,39 +555,78 @@ func (c *lndClient) OpenChannel(address string, amount decimal.Decimal, out chan
 	if err != nil {
 		return err
 	}
+	csvDelay := params.RemoteCsvDelay
+	if csvDelay == 0 {
+		csvDelay = DefaultCSVDelay
+	}
 	req := &lnrpc.OpenChannelRequest{
-		RemoteCsvDelay:     defaultCSVDelay,
+		RemoteCsvDelay:     csvDelay,
 		NodePubkey:         pubKey,
 		LocalFundingAmount: btcToSatoshi(amount),
-		Private:            true,
+		PushSat:            btcToSatoshi(params.PushAmount),
+		SatPerByte:         params.SatPerByte,
+		TargetConf:         params.TargetConf,
+		MinHtlcMsat:        btcToMillisatoshi(params.MinHtlc),
+		Private:            params.Private,
+		MinConfs:           params.MinConfs,
+		SpendUnconfirmed:   params.MinConfs == 0,
 	}
-	stream, err := c.client.OpenChannel(context.Background(), req)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.client.OpenChannel(ctx, req)
 	if err != nil {
+		cancel()
 		return err
 	}
 	go func() {
+		<-done
+		cancel()
+	}()
+	go func() {
+		channel := ChannelStatus{Node: addrParts[0], Status: "pending_open", Capacity: amount}
+		send := func(res *OpenChannelResult) bool {
+			select {
+			case <-done:
+				return false
+			case out <- res:
+				return res.Error == nil && res.Status == "pending_open"
+			}
+		}
 		for {
 			resp, err := stream.Recv()
 			if err != nil {
-				out <- &OpenChannelResult{ChannelStatus: ChannelStatus{Node: address}, Error: err}
+				send(&OpenChannelResult{ChannelStatus: ChannelStatus{Node: address}, Error: err})
 				return
 			}
 
+			res := &OpenChannelResult{}
 			switch update := resp.Update.(type) {
 			case *lnrpc.OpenStatusUpdate_ChanPending:
 				txid, err := chainhash.NewHash(update.ChanPending.Txid)
 				if err != nil {
-					out <- &OpenChannelResult{ChannelStatus: ChannelStatus{Node: address}, Error: err}
-					return
+					res.Error = err
+					break
+				}
+				channel.ChannelPoint = fmt.Sprintf("%s:%d", txid.String(), update.ChanPending.OutputIndex)
+				res.ChannelStatus = channel
+			case *lnrpc.OpenStatusUpdate_ChanOpen:
+				channel.Status = "open"
+				res.ChannelStatus = channel
+				// Look up the open channel for its id and balances
+				channels, err := c.Channels()
+				if err != nil {
+					res.Error = err
+					break
 				}
-				out <- &OpenChannelResult{
-					ChannelStatus: ChannelStatus{
-						Node:         addrParts[0],
-						ChannelPoint: fmt.Sprintf("%s:%d", txid.String(), update.ChanPending.OutputIndex),
-						Status:       "pending_open",
-						Capacity:     amount,
-					},
+				for _, ch := range channels {
+					if ch.ChannelPoint == channel.ChannelPoint {
+						res.ChannelStatus = *ch
+						break
+					}
 				}
+			default:
+				continue
+			}
+			if !send(res) {
 				return
 			}
 		}
@@ -464,12 +718,14 @@ func (c *lndClient) ClosedChannels(offset, limit int) ([]*ClosedChannel, error)
 	return res, nil
 }
 
-// CloseChannel with specified channel point
-func (c *lndClient) CloseChannel(chanID uint64, chanPoint string) (*ChannelStatus, error) {
+// CloseChannel with specified channel point.
+// Pending and close updates are sent to out until the channel is closed or done is closed,
+// stream failure is sent as the last update
+func (c *lndClient) CloseChannel(chanID uint64, chanPoint string, params CloseChannelParams, out chan *CloseChannelResult, done chan struct{}) error {
 	// Find channel
 	list, err := c.Channels()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	var channel *ChannelStatus
 	for _, ch := range list {
@@ -479,51 +735,98 @@ func (c *lndClient) CloseChannel(chanID uint64, chanPoint string) (*ChannelStatu
 		}
 	}
 	if channel == nil {
-		return nil, fmt.Errorf("channel not found")
+		return fmt.Errorf("channel not found")
 	}
 
 	// Parse channel point
 	channelPoint := &lnrpc.ChannelPoint{}
 	chanPointParts := strings.Split(channel.ChannelPoint, ":")
 	if len(chanPointParts) != 2 {
-		return nil, errors.New("invalid ChannelPoint format")
+		return errors.New("invalid ChannelPoint format")
 	}
 	channelPoint.FundingTxid = &lnrpc.ChannelPoint_FundingTxidStr{
 		FundingTxidStr: chanPointParts[0],
 	}
 	index, err := strconv.ParseUint(chanPointParts[1], 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("inable to decode output index: %v", err)
+		return fmt.Errorf("inable to decode output index: %v", err)
 	}
 	channelPoint.OutputIndex = uint32(index)
 
-	// Close channel
-	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
-	defer cancel()
-	ch, err := c.client.CloseChannel(ctx, &lnrpc.CloseChannelRequest{ChannelPoint: channelPoint})
-	for {
-		m, err := ch.Recv()
-		if err != nil {
-			return nil, err
+	// Close channel, the stream lives until the closing transaction confirms
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.client.CloseChannel(ctx, &lnrpc.CloseChannelRequest{
+		ChannelPoint: channelPoint,
+		Force:        params.Force,
+		SatPerByte:   params.SatPerByte,
+		TargetConf:   params.TargetConf,
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		<-done
+		cancel()
+	}()
+	go func() {
+		send := func(res *CloseChannelResult) bool {
+			select {
+			case <-done:
+				return false
+			case out <- res:
+				return res.Error == nil && res.Status != "closed"
+			}
 		}
-		switch x := m.Update.(type) {
-		case *lnrpc.CloseStatusUpdate_ClosePending:
-			closingHash := x.ClosePending.Txid
-			txid, err := chainhash.NewHash(closingHash)
+		for {
+			resp, err := stream.Recv()
 			if err != nil {
-				return nil, err
+				send(&CloseChannelResult{ChannelStatus: *channel, Error: err})
+				return
+			}
+
+			res := &CloseChannelResult{}
+			switch update := resp.Update.(type) {
+			case *lnrpc.CloseStatusUpdate_ClosePending:
+				txid, err := chainhash.NewHash(update.ClosePending.Txid)
+				if err != nil {
+					res.Error = err
+					break
+				}
+				channel.ClosingTxid = txid.String()
+				channel.Status = "waiting_close"
+				if params.Force {
+					channel.Status = "pending_force_closing"
+				}
+				res.ChannelStatus = *channel
+			case *lnrpc.CloseStatusUpdate_ChanClose:
+				txid, err := chainhash.NewHash(update.ChanClose.ClosingTxid)
+				if err != nil {
+					res.Error = err
+					break
+				}
+				channel.ClosingTxid = txid.String()
+				channel.Status = "closed"
+				res.ChannelStatus = *channel
+			default:
+				continue
+			}
+			if !send(res) {
+				return
 			}
-			channel.ClosingTxid = txid.String()
-			channel.Status = "waiting_close"
-			return channel, nil
 		}
-	}
-	return channel, nil
+	}()
+	return nil
 }
 
-// SendPayment by specified payment request on specified amount
-func (c *lndClient) SendPayment(paymentReq string, amount decimal.Decimal, chanID uint64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+// SendPayment by specified payment request on specified amount and wait for its final state.
+// If timeout elapses earlier the payment is reported in flight
+func (c *lndClient) SendPayment(paymentReq string, amount decimal.Decimal, chanID uint64, timeout time.Duration) (*PaymentResult, error) {
+	hash, err := c.PaymentHash(paymentReq)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	resp, err := c.client.SendPaymentSync(ctx, &lnrpc.SendRequest{
 		PaymentRequest: paymentReq,
@@ -531,14 +834,75 @@ func (c *lndClient) SendPayment(paymentReq string, amount decimal.Decimal, chanI
 		OutgoingChanId: chanID,
 	})
 	if err != nil {
-		return err
+		if status.Code(err) != codes.DeadlineExceeded {
+			return nil, err
+		}
+		// Payment is still in flight, report the state lnd has recorded
+		p, err := c.FindPayment(hash)
+		if err != nil {
+			return nil, err
+		}
+		res := &PaymentResult{PaymentHash: hash, Status: PaymentInFlight, Amount: amount}
+		if p != nil {
+			res.Status = p.Status
+			res.Fee = p.Fee
+			res.Preimage = p.Preimage
+		}
+		return res, nil
 	}
 	if resp.PaymentError != "" {
-		return errors.New(resp.PaymentError)
+		return &PaymentResult{PaymentHash: hash, Status: PaymentFailed, Amount: amount, Error: resp.PaymentError}, nil
 	}
-	return nil
+	res := &PaymentResult{
+		PaymentHash: hash,
+		Status:      PaymentSucceeded,
+		Amount:      amount,
+		Preimage:    hex.EncodeToString(resp.PaymentPreimage),
+	}
+	if r := resp.PaymentRoute; r != nil {
+		res.Fee = msatToBTC(r.TotalFeesMsat)
+		for _, h := range r.Hops {
+			res.Route = append(res.Route, RouteHop{
+				ChanID:  h.ChanId,
+				PubKey:  h.PubKey,
+				Forward: msatToBTC(h.AmtToForwardMsat),
+				Fee:     msatToBTC(h.FeeMsat),
+				Expiry:  h.Expiry,
+			})
+		}
+	}
+	return res, nil
+}
+
+// PaymentHash of specified payment request
+func (c *lndClient) PaymentHash(paymentReq string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	resp, err := c.client.DecodePayReq(ctx, &lnrpc.PayReqString{PayReq: paymentReq})
+	if err != nil {
+		return "", err
+	}
+	return resp.PaymentHash, nil
 }
 
+// FindPayment including incomplete ones by its hash, nil if not found
+func (c *lndClient) FindPayment(paymentHash string) (*Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	resp, err := c.client.ListPayments(ctx, &lnrpc.ListPaymentsRequest{IncludeIncomplete: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range resp.Payments {
+		if p.PaymentHash == paymentHash {
+			res := payment(p)
+			return &res, nil
+		}
+	}
+	return nil, nil
+}
+
+// Payments list
 func (c *lndClient) Payments(offset, limit int) ([]Payment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
 	defer cancel()
@@ -556,15 +920,69 @@ func (c *lndClient) Payments(offset, limit int) ([]Payment, error) {
 		last = len(resp.Payments)
 	}
 	for _, p := range resp.Payments[offset:last] {
-		res = append(res, Payment{
-			Node:      p.Path[0],
-			Timestamp: time.Unix(p.CreationDate, 0),
-			Amount:    satoshiToBTC(p.ValueSat),
-		})
+		res = append(res, payment(p))
 	}
 	return res, nil
 }
 
+// AddInvoice on specified amount to be paid to the local node
+func (c *lndClient) AddInvoice(amount decimal.Decimal, memo string) (*LocalInvoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	resp, err := c.client.AddInvoice(ctx, &lnrpc.Invoice{
+		Memo:  memo,
+		Value: btcToSatoshi(amount),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &LocalInvoice{
+		PaymentHash:    hex.EncodeToString(resp.RHash),
+		PaymentRequest: resp.PaymentRequest,
+		Amount:         amount,
+		Status:         InvoiceStatusOpen,
+	}, nil
+}
+
+// WaitInvoice until it's settled or canceled, its current state is returned if timeout elapses earlier
+func (c *lndClient) WaitInvoice(paymentHash string, timeout time.Duration) (*LocalInvoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// Subscribe before the lookup so that no update is missed in between
+	stream, err := c.client.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{})
+	if err != nil {
+		return nil, err
+	}
+	inv, err := c.lookupInvoice(paymentHash)
+	if err != nil {
+		return nil, err
+	}
+	for inv.Status == InvoiceStatusOpen {
+		update, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) == codes.DeadlineExceeded {
+				return inv, nil
+			}
+			return nil, err
+		}
+		if hex.EncodeToString(update.RHash) == paymentHash {
+			inv = localInvoice(update)
+		}
+	}
+	return inv, nil
+}
+
+// lookupInvoice of the local node by its payment hash
+func (c *lndClient) lookupInvoice(paymentHash string) (*LocalInvoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	resp, err := c.client.LookupInvoice(ctx, &lnrpc.PaymentHash{RHashStr: paymentHash})
+	if err != nil {
+		return nil, err
+	}
+	return localInvoice(resp), nil
+}
+
 func (c *lndClient) Transactions(offset, limit int) ([]Transaction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
 	defer cancel()
@@ -582,19 +1000,60 @@ func (c *lndClient) Transactions(offset, limit int) ([]Transaction, error) {
 		last = len(resp.Transactions)
 	}
 	for _, t := range resp.Transactions[offset:last] {
-		res = append(res, Transaction{
-			TxID:             t.TxHash,
-			Amount:           satoshiToBTC(t.Amount),
-			NumConfirmations: t.NumConfirmations,
-			BlockHeight:      t.BlockHeight,
-			Timestamp:        time.Unix(t.TimeStamp, 0),
-			TotalFees:        satoshiToBTC(t.TotalFees),
-			DestAddresses:    t.DestAddresses,
-		})
+		res = append(res, *transaction(t))
 	}
 	return res, nil
 }
 
+// Transaction of the wallet by its id, nil if not found
+func (c *lndClient) Transaction(txid string) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+	resp, err := c.client.GetTransactions(ctx, &lnrpc.GetTransactionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range resp.Transactions {
+		if t.TxHash == txid {
+			return transaction(t), nil
+		}
+	}
+	return nil, nil
+}
+
+// SubscribeTransactions of the wallet sending their updates to out until done is closed.
+// Subscription failure is sent as the last update
+func (c *lndClient) SubscribeTransactions(out chan *TransactionUpdate, done chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := c.client.SubscribeTransactions(ctx, &lnrpc.GetTransactionsRequest{})
+	if err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		<-done
+		cancel()
+	}()
+	go func() {
+		for {
+			t, err := stream.Recv()
+			update := &TransactionUpdate{Error: err}
+			if err == nil {
+				update.Transaction = transaction(t)
+			}
+			select {
+			case <-done:
+				return
+			case out <- update:
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
 // Close gRPC connection
 func (c *lndClient) Close() error {
 	if c.connection != nil {
@@ -615,17 +1074,25 @@ func btcToSatoshi(btc decimal.Decimal) int64 {
 
 func channelStatus(c *lnrpc.Channel, status string) *ChannelStatus {
 	return &ChannelStatus{
-		ID:            c.ChanId,
-		Node:          c.RemotePubkey,
-		ChannelPoint:  c.ChannelPoint,
-		Capacity:      satoshiToBTC(c.Capacity),
-		LocalBalance:  satoshiToBTC(c.LocalBalance),
-		RemoteBalance: satoshiToBTC(c.RemoteBalance),
-		LocalReserved: satoshiToBTC(c.LocalChanReserveSat),
-		Status:        status,
+		ID:             c.ChanId,
+		ShortChannelID: shortChannelID(c.ChanId),
+		Node:           c.RemotePubkey,
+		ChannelPoint:   c.ChannelPoint,
+		Capacity:       satoshiToBTC(c.Capacity),
+		LocalBalance:   satoshiToBTC(c.LocalBalance),
+		RemoteBalance:  satoshiToBTC(c.RemoteBalance),
+		LocalReserved:  satoshiToBTC(c.LocalChanReserveSat),
+		RemoteReserved: satoshiToBTC(c.RemoteChanReserveSat),
+		CsvDelay:       c.CsvDelay,
+		Status:         status,
 	}
 }
 
+// shortChannelID formats channel id as block height, transaction index and output index
+func shortChannelID(id uint64) string {
+	return fmt.Sprintf("%dx%dx%d", id>>40, id>>16&0xFFFFFF, id&0xFFFF)
+}
+
 func pendingChannelStatus(c *lnrpc.PendingChannelsResponse_PendingChannel, status string) *ChannelStatus {
 	return &ChannelStatus{
 		Node:          c.RemoteNodePub,
@@ -637,6 +1104,57 @@ func pendingChannelStatus(c *lnrpc.PendingChannelsResponse_PendingChannel, statu
 	}
 }
 
+func msatToBTC(msat int64) decimal.Decimal {
+	return decimal.New(msat, -11)
+}
+
+func btcToMillisatoshi(btc decimal.Decimal) int64 {
+	return btc.Mul(decimal.New(1, 11)).IntPart()
+}
+
+func transaction(t *lnrpc.Transaction) *Transaction {
+	return &Transaction{
+		TxID:             t.TxHash,
+		Amount:           satoshiToBTC(t.Amount),
+		NumConfirmations: t.NumConfirmations,
+		BlockHeight:      t.BlockHeight,
+		Timestamp:        time.Unix(t.TimeStamp, 0),
+		TotalFees:        satoshiToBTC(t.TotalFees),
+		DestAddresses:    t.DestAddresses,
+	}
+}
+
+func localInvoice(i *lnrpc.Invoice) *LocalInvoice {
+	res := &LocalInvoice{
+		PaymentHash:    hex.EncodeToString(i.RHash),
+		PaymentRequest: i.PaymentRequest,
+		Amount:         satoshiToBTC(i.Value),
+		AmountPaid:     satoshiToBTC(i.AmtPaidSat),
+		Status:         strings.ToLower(i.State.String()),
+	}
+	if i.SettleDate != 0 {
+		settledAt := time.Unix(i.SettleDate, 0)
+		res.SettledAt = &settledAt
+	}
+	return res
+}
+
+func payment(p *lnrpc.Payment) Payment {
+	res := Payment{
+		PaymentHash: p.PaymentHash,
+		Timestamp:   time.Unix(p.CreationDate, 0),
+		Amount:      satoshiToBTC(p.ValueSat),
+		Fee:         satoshiToBTC(p.Fee),
+		Status:      strings.ToLower(p.Status.String()),
+		Preimage:    p.PaymentPreimage,
+	}
+	// Incomplete payments have no path
+	if len(p.Path) > 0 {
+		res.Node = p.Path[0]
+	}
+	return res
+}
+
 func closedChannel(c *lnrpc.ChannelCloseSummary) *ClosedChannel {
 	return &ClosedChannel{
 		ID:                c.ChanId,
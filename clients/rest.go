@@ -11,17 +11,21 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	mathrand "math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/secret"
 )
 
 const (
 	defaultRequestTimeout = 30 * time.Second
+	maxErrorBodyMessage   = 512
 )
 
 // RestClient interface for Xena dAccs API
@@ -32,10 +36,23 @@ type RestClient interface {
 	RemoteNodes() ([]*Node, error)
 	// RemoteAddresses of Xena lnd nodes to connect to
 	RemoteAddresses() ([]string, error)
-	// IssueInvoices to pay via available channels
-	IssueInvoices(accountID int64, chanPoints []string) ([]*Invoice, error)
+	// IssueInvoices to pay via available channels, externalID identifies the request
+	// so repeated requests with the same externalID get the same invoices
+	IssueInvoices(accountID int64, externalID string, chanPoints []string) ([]*Invoice, error)
 	// Limits returns daccs limits
 	Limits() (*Limits, error)
+	// InvoiceStatus of the issued invoice by its payment hash
+	InvoiceStatus(paymentHash string) (*InvoiceStatus, error)
+	// RequestWithdrawal from account by paying the local node invoice via specified channel
+	RequestWithdrawal(accountID int64, paymentRequest, chanPoint string) (*Withdrawal, error)
+	// Accounts of Xena user
+	Accounts() ([]*Account, error)
+	// Balance of specified account
+	Balance(accountID int64) (*AccountBalance, error)
+	// Deposits to specified account, last ones first
+	Deposits(accountID int64, offset, limit int) ([]*Deposit, error)
+	// MarkPrice of specified symbol, e.g. XBTUSD
+	MarkPrice(symbol string) (*MarkPrice, error)
 }
 
 // restClient implementation
@@ -44,6 +61,49 @@ type restClient struct {
 	baseURL   *url.URL
 	apiKey    string
 	apiSecret *ecdsa.PrivateKey
+	retry     *retryPolicy
+}
+
+// retryPolicy of API calls with exponential backoff and jitter
+type retryPolicy struct {
+	// maxRetries after the first attempt
+	maxRetries int
+	// minWait before the first retry, doubled on every next one
+	minWait time.Duration
+	// maxWait between attempts unless server asks for more with Retry-After
+	maxWait time.Duration
+	// jitter returns random duration in [0, d)
+	jitter func(d time.Duration) time.Duration
+}
+
+// retryAfterFactor of maxWait the server provided Retry-After is honoured up to
+const retryAfterFactor = 4
+
+// retryAfterCap the server provided Retry-After is honoured up to, the call fails if server asks for more
+func (p *retryPolicy) retryAfterCap() time.Duration {
+	return p.maxWait * retryAfterFactor
+}
+
+// wait before retry attempt (counting from 0) honouring server provided Retry-After up to its cap
+func (p *retryPolicy) wait(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.maxWait
+	if attempt < 32 && p.minWait<<uint(attempt) < p.maxWait {
+		d = p.minWait << uint(attempt)
+	}
+	d = d/2 + p.jitter(d/2)
+	if retryAfter > p.retryAfterCap() {
+		retryAfter = p.retryAfterCap()
+	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
+
+// temporaryError of API call which could be retried
+type temporaryError struct {
+	error
+	retryAfter time.Duration
 }
 
 // NewRestClient constructor
@@ -57,23 +117,25 @@ func NewRestClient(c *cli.Context) (RestClient, error) {
 	if apiKey == "" {
 		return nil, errors.New("api-key is not specified")
 	}
-	apiSecret := c.GlobalString("api-secret")
-	if apiSecret == "" {
-		return nil, errors.New("api-secret is not specified")
-	}
 
 	baseURL, err := url.Parse(apiURL)
 	if err != nil {
 		return nil, fmt.Errorf("Error %s on parsing api-url", err)
 	}
 
-	privKeyData, err := hex.DecodeString(apiSecret)
+	privKey, err := LoadAPISecret(c)
 	if err != nil {
-		return nil, fmt.Errorf("Error %s on decoding api-secret", err)
+		return nil, err
 	}
-	privKey, err := x509.ParseECPrivateKey(privKeyData)
-	if err != nil {
-		return nil, fmt.Errorf("Error %s on parsing api-secret", err)
+
+	retries := c.GlobalInt("api-retries")
+	if retries < 0 {
+		return nil, errors.New("api-retries should not be negative")
+	}
+	minWait := c.GlobalDuration("api-retry-min-wait")
+	maxWait := c.GlobalDuration("api-retry-max-wait")
+	if minWait <= 0 || maxWait < minWait {
+		return nil, errors.New("api-retry-min-wait should be positive and not greater than api-retry-max-wait")
 	}
 
 	return &restClient{
@@ -83,15 +145,58 @@ func NewRestClient(c *cli.Context) (RestClient, error) {
 		baseURL:   baseURL,
 		apiKey:    apiKey,
 		apiSecret: privKey,
+		retry: &retryPolicy{
+			maxRetries: retries,
+			minWait:    minWait,
+			maxWait:    maxWait,
+			jitter: func(d time.Duration) time.Duration {
+				if d <= 0 {
+					return 0
+				}
+				return time.Duration(mathrand.Int63n(int64(d)))
+			},
+		},
 	}, nil
 }
 
+// LoadAPISecret private key given either as hex by api-secret,
+// or by secret agent listening on api-secret-agent socket,
+// or as encrypted api-secret-file to be decrypted with passphrase prompted
+func LoadAPISecret(c *cli.Context) (*ecdsa.PrivateKey, error) {
+	var privKeyData []byte
+	var err error
+	switch {
+	case c.GlobalString("api-secret") != "":
+		privKeyData, err = hex.DecodeString(c.GlobalString("api-secret"))
+		if err != nil {
+			return nil, fmt.Errorf("Error %s on decoding api-secret", err)
+		}
+	case c.GlobalString("api-secret-agent") != "":
+		privKeyData, err = secret.FromAgent(c.GlobalString("api-secret-agent"))
+		if err != nil {
+			return nil, err
+		}
+	case c.GlobalString("api-secret-file") != "":
+		privKeyData, err = secret.LoadAndDecrypt(c.GlobalString("api-secret-file"))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("api-secret is not specified")
+	}
+	privKey, err := x509.ParseECPrivateKey(privKeyData)
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on parsing api-secret", err)
+	}
+	return privKey, nil
+}
+
 // RegisterNode registers local lnd node in assoiciation with Xena user
 func (c *restClient) RegisterNode(pubKey string) error {
 	req := &addPubKeyRequest{
 		PubKey: pubKey,
 	}
-	_, err := c.call("pubkey", "POST", req)
+	_, err := c.call("pubkey", "POST", req, false)
 	if err != nil {
 		return err
 	}
@@ -100,7 +205,7 @@ func (c *restClient) RegisterNode(pubKey string) error {
 
 // RemoteNodes list Xena lnd nodes to connect to
 func (c *restClient) RemoteNodes() ([]*Node, error) {
-	respData, err := c.call("nodes", "GET", nil)
+	respData, err := c.call("nodes", "GET", nil, true)
 	if err != nil {
 		return nil, err
 	}
@@ -126,12 +231,13 @@ func (c *restClient) RemoteAddresses() ([]string, error) {
 }
 
 // IssueInvoices to pay via specified channels
-func (c *restClient) IssueInvoices(accountID int64, chanPoints []string) ([]*Invoice, error) {
+func (c *restClient) IssueInvoices(accountID int64, externalID string, chanPoints []string) ([]*Invoice, error) {
 	req := invoiceRequest{
-		ExternalID: time.Now().UTC().String(),
+		ExternalID: externalID,
 		ChanPoints: chanPoints,
 	}
-	respData, err := c.call(fmt.Sprintf("accounts/%d/invoices", accountID), "POST", &req)
+	// ExternalID makes the request idempotent, so it's safe to retry
+	respData, err := c.call(fmt.Sprintf("accounts/%d/invoices", accountID), "POST", &req, true)
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +251,7 @@ func (c *restClient) IssueInvoices(accountID int64, chanPoints []string) ([]*Inv
 
 // Limits returns daccs limits
 func (c *restClient) Limits() (*Limits, error) {
-	respData, err := c.call("limits", "GET", nil)
+	respData, err := c.call("limits", "GET", nil, true)
 	if err != nil {
 		return nil, err
 	}
@@ -157,8 +263,98 @@ func (c *restClient) Limits() (*Limits, error) {
 	return resp, nil
 }
 
-// call Xena dAccs API with authentication
-func (c *restClient) call(path, method string, request interface{}) ([]byte, error) {
+// InvoiceStatus of the issued invoice by its payment hash
+func (c *restClient) InvoiceStatus(paymentHash string) (*InvoiceStatus, error) {
+	respData, err := c.call("invoices/"+url.PathEscape(paymentHash), "GET", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	resp := &InvoiceStatus{}
+	err = json.Unmarshal(respData, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RequestWithdrawal from account by paying the local node invoice via specified channel
+func (c *restClient) RequestWithdrawal(accountID int64, paymentRequest, chanPoint string) (*Withdrawal, error) {
+	req := withdrawalRequest{
+		PaymentRequest: paymentRequest,
+		ChanPoint:      chanPoint,
+	}
+	// Retry after lost response could create second withdrawal on the server, so it's not retried
+	respData, err := c.call(fmt.Sprintf("accounts/%d/withdrawals", accountID), "POST", &req, false)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Withdrawal{}
+	err = json.Unmarshal(respData, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Accounts of Xena user
+func (c *restClient) Accounts() ([]*Account, error) {
+	respData, err := c.call("accounts", "GET", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	var resp []*Account
+	err = json.Unmarshal(respData, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Balance of specified account
+func (c *restClient) Balance(accountID int64) (*AccountBalance, error) {
+	respData, err := c.call(fmt.Sprintf("accounts/%d/balance", accountID), "GET", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	resp := &AccountBalance{}
+	err = json.Unmarshal(respData, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Deposits to specified account, last ones first
+func (c *restClient) Deposits(accountID int64, offset, limit int) ([]*Deposit, error) {
+	path := fmt.Sprintf("accounts/%d/deposits?offset=%d&limit=%d", accountID, offset, limit)
+	respData, err := c.call(path, "GET", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	var resp []*Deposit
+	err = json.Unmarshal(respData, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// MarkPrice of specified symbol, e.g. XBTUSD
+func (c *restClient) MarkPrice(symbol string) (*MarkPrice, error) {
+	respData, err := c.call("market-data/mark-price/"+url.PathEscape(symbol), "GET", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	resp := &MarkPrice{}
+	err = json.Unmarshal(respData, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// call Xena dAccs API with authentication, retrying temporary failures if the call is retryable
+func (c *restClient) call(path, method string, request interface{}, retryable bool) ([]byte, error) {
 	// Request URL
 	urlPath, err := url.Parse(path)
 	if err != nil {
@@ -166,17 +362,34 @@ func (c *restClient) call(path, method string, request interface{}) ([]byte, err
 	}
 
 	// Prepare request body if any
-	reqBody := &bytes.Buffer{}
+	var bodyData []byte
 	if request != nil {
-		bodyData, err := json.Marshal(request)
+		bodyData, err = json.Marshal(request)
 		if err != nil {
 			return nil, fmt.Errorf("%s on marshaling request body", err)
 		}
-		reqBody = bytes.NewBuffer(bodyData)
 	}
 
+	for attempt := 0; ; attempt++ {
+		data, err := c.do(method, c.baseURL.ResolveReference(urlPath), bodyData)
+		if err == nil {
+			return data, nil
+		}
+		tempErr, ok := err.(*temporaryError)
+		if !ok {
+			return nil, err
+		}
+		if !retryable || attempt >= c.retry.maxRetries || tempErr.retryAfter > c.retry.retryAfterCap() {
+			return nil, tempErr.error
+		}
+		time.Sleep(c.retry.wait(attempt, tempErr.retryAfter))
+	}
+}
+
+// do single authenticated request attempt
+func (c *restClient) do(method string, reqURL *url.URL, bodyData []byte) ([]byte, error) {
 	// Prepare HTTP request
-	req, err := http.NewRequest(method, c.baseURL.ResolveReference(urlPath).String(), reqBody)
+	req, err := http.NewRequest(method, reqURL.String(), bytes.NewReader(bodyData))
 	if err != nil {
 		return nil, fmt.Errorf("%s on creating HTTP request", err)
 	}
@@ -196,27 +409,59 @@ func (c *restClient) call(path, method string, request interface{}) ([]byte, err
 	// Issue request and obtain response
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("%s on performing %s request to %s", err, req.Method, req.URL)
+		return nil, &temporaryError{error: &APIError{Method: req.Method, Path: req.URL.Path, Message: err.Error()}}
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("%s on reading response", err)
+		return nil, &temporaryError{error: &APIError{Method: req.Method, Path: req.URL.Path, Message: fmt.Sprintf("%s on reading response", err)}}
 	}
 
 	// Check response for error
 	var errResp errorResponse
-	err = json.Unmarshal(data, &errResp)
-	if err == nil && errResp.Error != "" {
-		return nil, errors.New(errResp.Error)
+	jsonErr := json.Unmarshal(data, &errResp)
+	if resp.StatusCode != 200 || (jsonErr == nil && errResp.Error != "") {
+		code := strings.Trim(string(errResp.Code), `"`)
+		if code == "null" {
+			code = ""
+		}
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			Message:    errResp.Error,
+			Code:       code,
+			RequestID:  resp.Header.Get("X-Request-Id"),
+		}
+		if jsonErr != nil {
+			apiErr.Message = strings.TrimSpace(string(data))
+			if len(apiErr.Message) > maxErrorBodyMessage {
+				apiErr.Message = apiErr.Message[:maxErrorBodyMessage]
+			}
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, &temporaryError{error: apiErr, retryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return nil, apiErr
 	}
 
 	return data, nil
 }
 
+// retryAfter parses Retry-After header value given in seconds or as HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(value); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
 // addPubKeyRequest message
 type addPubKeyRequest struct {
 	PubKey string `json:"pubKey"`
@@ -242,11 +487,85 @@ type Invoice struct {
 	ChanPoint      string `json:"chanPoint"`
 }
 
+// Invoice statuses
+const (
+	InvoiceOpen     = "open"
+	InvoiceSettled  = "settled"
+	InvoiceCanceled = "canceled"
+)
+
+// InvoiceStatus message
+type InvoiceStatus struct {
+	PaymentHash string          `json:"paymentHash"`
+	AccountID   int64           `json:"accountId"`
+	ExternalID  string          `json:"externalId"`
+	Status      string          `json:"status"`
+	Amount      decimal.Decimal `json:"amount" unit:"btc"`
+}
+
+// withdrawalRequest message
+type withdrawalRequest struct {
+	PaymentRequest string `json:"paymentRequest"`
+	ChanPoint      string `json:"chanPoint"`
+}
+
+// Withdrawal message
+type Withdrawal struct {
+	ID          string          `json:"id"`
+	AccountID   int64           `json:"accountId"`
+	PaymentHash string          `json:"paymentHash"`
+	Amount      decimal.Decimal `json:"amount" unit:"btc"`
+	Status      string          `json:"status"`
+}
+
+// Account message
+type Account struct {
+	ID       int64  `json:"id"`
+	Kind     string `json:"kind"`
+	Currency string `json:"currency"`
+}
+
+// AccountBalance message, amounts are in Currency of the account
+type AccountBalance struct {
+	AccountID int64           `json:"accountId"`
+	Currency  string          `json:"currency" unit:"currency"`
+	Total     decimal.Decimal `json:"total" unit:"btc"`
+	Available decimal.Decimal `json:"available" unit:"btc"`
+}
+
+// Deposit message
+type Deposit struct {
+	AccountID   int64           `json:"accountId"`
+	ExternalID  string          `json:"externalId"`
+	PaymentHash string          `json:"paymentHash"`
+	Amount      decimal.Decimal `json:"amount" unit:"btc"`
+	Status      string          `json:"status"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// MarkPrice message
+type MarkPrice struct {
+	Symbol    string          `json:"symbol"`
+	Price     decimal.Decimal `json:"price"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
 // Limits message
 type Limits struct {
-	MinChannelCapacity       decimal.Decimal `json:"minChannelCapacity"`
-	MinPaymentAmount         decimal.Decimal `json:"minPaymentAmount"`
-	ChannelReserveMultiplier decimal.Decimal `json:"channelReserveMultiplier"`
+	MinChannelCapacity       decimal.Decimal   `json:"minChannelCapacity" unit:"btc"`
+	MinPaymentAmount         decimal.Decimal   `json:"minPaymentAmount" unit:"btc"`
+	ChannelReserveMultiplier decimal.Decimal   `json:"channelReserveMultiplier"`
+	ChannelOpen              ChannelOpenLimits `json:"channelOpen"`
+}
+
+// ChannelOpenLimits constraints on channel open parameters, zero delays and amounts
+// and nil PublicAllowed impose none
+type ChannelOpenLimits struct {
+	MinRemoteCsvDelay uint32          `json:"minRemoteCsvDelay"`
+	MaxRemoteCsvDelay uint32          `json:"maxRemoteCsvDelay"`
+	MaxPushAmount     decimal.Decimal `json:"maxPushAmount" unit:"btc"`
+	MaxMinHtlc        decimal.Decimal `json:"maxMinHtlc" unit:"btc"`
+	PublicAllowed     *bool           `json:"publicAllowed,omitempty"`
 }
 
 type Node struct {
@@ -256,5 +575,31 @@ type Node struct {
 
 // error message
 type errorResponse struct {
-	Error string `json:"error"`
+	Error string          `json:"error"`
+	Code  json.RawMessage `json:"code"`
+}
+
+// APIError returned by Xena dAccs API, StatusCode is 0 if no response was received
+type APIError struct {
+	StatusCode int    `json:"status_code"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Message    string `json:"message,omitempty"`
+	Code       string `json:"code,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+// Error implements error interface
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s (code %s)", msg, e.Code)
+	}
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.Path, msg)
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, msg)
 }
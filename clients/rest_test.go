@@ -0,0 +1,218 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyWait(t *testing.T) {
+	half := func(d time.Duration) time.Duration { return d / 2 }
+	none := func(d time.Duration) time.Duration { return 0 }
+	tests := []struct {
+		name       string
+		jitter     func(time.Duration) time.Duration
+		attempt    int
+		retryAfter time.Duration
+		wait       time.Duration
+	}{
+		{"first without jitter", none, 0, 0, 250 * time.Millisecond},
+		{"first with jitter", half, 0, 0, 375 * time.Millisecond},
+		{"doubled", none, 1, 0, 500 * time.Millisecond},
+		{"doubled twice", none, 2, 0, time.Second},
+		{"max", half, 5, 0, 7500 * time.Millisecond},
+		{"max of shift overflow", none, 40, 0, 5 * time.Second},
+		{"retry after below backoff", none, 2, 100 * time.Millisecond, time.Second},
+		{"retry after above backoff", none, 0, 20 * time.Second, 20 * time.Second},
+		{"retry after cap", none, 0, 40 * time.Second, 40 * time.Second},
+		{"retry after above cap", none, 0, time.Hour, 40 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &retryPolicy{maxRetries: 3, minWait: 500 * time.Millisecond, maxWait: 10 * time.Second, jitter: tt.jitter}
+			assert.Equal(t, tt.wait, p.wait(tt.attempt, tt.retryAfter))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), retryAfter(""))
+	assert.Equal(t, time.Duration(0), retryAfter("soon"))
+	assert.Equal(t, time.Duration(0), retryAfter("-5"))
+	assert.Equal(t, 120*time.Second, retryAfter("120"))
+	d := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, d > 55*time.Second && d <= time.Minute, "%s", d)
+}
+
+func TestRestClientRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		call       func(c *restClient) error
+		failures   int32
+		retryAfter string
+		requests   int32
+		status     int
+	}{
+		{
+			name:     "retried until success",
+			call:     func(c *restClient) error { _, err := c.Limits(); return err },
+			failures: 2,
+			requests: 3,
+		},
+		{
+			name:     "retries exhausted",
+			call:     func(c *restClient) error { _, err := c.Limits(); return err },
+			failures: 10,
+			requests: 3,
+			status:   503,
+		},
+		{
+			name:       "retry after above cap",
+			call:       func(c *restClient) error { _, err := c.Limits(); return err },
+			failures:   1,
+			retryAfter: "3600",
+			requests:   1,
+			status:     503,
+		},
+		{
+			name:     "withdrawal not retried",
+			call:     func(c *restClient) error { _, err := c.RequestWithdrawal(1, "lnbc1", "tx1:0"); return err },
+			failures: 1,
+			requests: 1,
+			status:   503,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(`{"error":"maintenance"}`))
+					return
+				}
+				w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+
+			err := tt.call(testRestClient(t, srv.URL))
+			assert.Equal(t, tt.requests, atomic.LoadInt32(&requests))
+			if tt.status == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			apiErr, ok := err.(*APIError)
+			require.True(t, ok, "%T", err)
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+			assert.Equal(t, "maintenance", apiErr.Message)
+		})
+	}
+}
+
+// testRestClient of server at baseURL with short retry waits
+func testRestClient(t *testing.T, baseURL string) *restClient {
+	u, err := url.Parse(baseURL + "/")
+	require.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &restClient{
+		client:    &http.Client{Timeout: time.Second},
+		baseURL:   u,
+		apiKey:    "key",
+		apiSecret: key,
+		retry: &retryPolicy{
+			maxRetries: 2,
+			minWait:    time.Millisecond,
+			maxWait:    5 * time.Millisecond,
+			jitter:     func(time.Duration) time.Duration { return 0 },
+		},
+	}
+}
+
+func TestRestClientAPIError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		err     APIError
+		message string
+	}{
+		{
+			name:    "error with code",
+			status:  http.StatusBadRequest,
+			body:    `{"error":"amount is too small","code":"LIMIT"}`,
+			err:     APIError{StatusCode: 400, Method: "GET", Path: "/limits", Message: "amount is too small", Code: "LIMIT", RequestID: "req-1"},
+			message: "GET /limits: 400 amount is too small (code LIMIT)",
+		},
+		{
+			name:    "numeric code",
+			status:  http.StatusUnauthorized,
+			body:    `{"error":"invalid signature","code":401}`,
+			err:     APIError{StatusCode: 401, Method: "GET", Path: "/limits", Message: "invalid signature", Code: "401", RequestID: "req-1"},
+			message: "GET /limits: 401 invalid signature (code 401)",
+		},
+		{
+			name:    "error in successful response",
+			status:  http.StatusOK,
+			body:    `{"error":"not allowed","code":null}`,
+			err:     APIError{StatusCode: 200, Method: "GET", Path: "/limits", Message: "not allowed", RequestID: "req-1"},
+			message: "GET /limits: 200 not allowed",
+		},
+		{
+			name:    "body not json",
+			status:  http.StatusForbidden,
+			body:    "  access denied\n",
+			err:     APIError{StatusCode: 403, Method: "GET", Path: "/limits", Message: "access denied", RequestID: "req-1"},
+			message: "GET /limits: 403 access denied",
+		},
+		{
+			name:    "empty body",
+			status:  http.StatusNotFound,
+			err:     APIError{StatusCode: 404, Method: "GET", Path: "/limits", RequestID: "req-1"},
+			message: "GET /limits: 404 Not Found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-Id", "req-1")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := testRestClient(t, srv.URL).Limits()
+			require.Error(t, err)
+			apiErr, ok := err.(*APIError)
+			require.True(t, ok, "%T", err)
+			assert.Equal(t, tt.err, *apiErr)
+			assert.Equal(t, tt.message, apiErr.Error())
+		})
+	}
+}
+
+func TestRestClientNoResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	_, err := testRestClient(t, srv.URL).Limits()
+	require.Error(t, err)
+	apiErr, ok := err.(*APIError)
+	require.True(t, ok, "%T", err)
+	assert.Equal(t, 0, apiErr.StatusCode)
+	assert.Equal(t, "/limits", apiErr.Path)
+	assert.NotEqual(t, "", apiErr.Message)
+}
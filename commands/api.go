@@ -1,11 +1,19 @@
 package commands
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/urfave/cli"
 	"github.com/xenaex/daccs-cli/clients"
+	"github.com/xenaex/daccs-cli/secret"
 )
 
 // Payment commands definition
@@ -19,12 +27,54 @@ var Api = cli.Command{
 			Usage:  "List Xena lnd nodes available to open channels with",
 			Action: nodesList,
 		},
+		{
+			Name:   "limits",
+			Usage:  "Show dAccs limits amounts are validated against",
+			Action: apiLimits,
+		},
+		{
+			Name:   "accounts",
+			Usage:  "List Xena accounts of the user",
+			Action: accountsList,
+		},
+		{
+			Name:   "balance",
+			Usage:  "Show balance of specified Xena account",
+			Action: accountBalance,
+			Flags: []cli.Flag{
+				cli.Int64Flag{Name: "account"},
+			},
+		},
+		{
+			Name:   "deposits",
+			Usage:  "List last deposits to specified Xena account with paging support",
+			Action: depositsList,
+			Flags: []cli.Flag{
+				cli.Int64Flag{Name: "account"},
+				cli.IntFlag{Name: "offset", Value: 0},
+				cli.IntFlag{Name: "limit", Value: 10},
+			},
+		},
+		{
+			Name:   "keygen",
+			Usage:  "Generate a new API key pair, store its private key and print public key to register with Xena",
+			Action: apiKeygen,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "no-encrypt", Usage: "Store private key in plaintext file readable by owner only instead of encrypting it with passphrase"},
+				cli.StringFlag{Name: "out", Usage: "Path of secret file (default ~/.config/daccs-cli/secrets/<profile>.json)"},
+			},
+		},
+		{
+			Name:   "pubkey",
+			Usage:  "Print public key of the configured API secret",
+			Action: apiPubKey,
+		},
 	},
 }
 
 // nodesList command handler
 func nodesList(c *cli.Context) error {
-	restcli, err := clients.NewRestClient(c)
+	restcli, err := Clients.Rest(c)
 	if err != nil {
 		return err
 	}
@@ -40,8 +90,138 @@ func nodesList(c *cli.Context) error {
 		}
 		nodes = append(nodes, &RemoteNode{ID: n.ID, PubKey: addrParts[0]})
 	}
-	ResponseJSON(nodes)
-	return nil
+	return Response(nodes)
+}
+
+// apiLimits command handler
+func apiLimits(c *cli.Context) error {
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return err
+	}
+	res, err := restcli.Limits()
+	if err != nil {
+		return wrapError(err, "getting Limits")
+	}
+	return Response(res)
+}
+
+// accountsList command handler
+func accountsList(c *cli.Context) error {
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return err
+	}
+	res, err := restcli.Accounts()
+	if err != nil {
+		return wrapError(err, "getting accounts")
+	}
+	return Response(res)
+}
+
+// accountBalance command handler
+func accountBalance(c *cli.Context) error {
+	account := c.Int64("account")
+	if account <= 0 {
+		return usageError("Invalid account")
+	}
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return err
+	}
+	res, err := restcli.Balance(account)
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("getting account %d balance", account))
+	}
+	return Response(res)
+}
+
+// depositsList command handler
+func depositsList(c *cli.Context) error {
+	account := c.Int64("account")
+	if account <= 0 {
+		return usageError("Invalid account")
+	}
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return err
+	}
+	res, err := restcli.Deposits(account, c.Int("offset"), c.Int("limit"))
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("getting account %d deposits", account))
+	}
+	return Response(res)
+}
+
+// apiKeygen command handler
+func apiKeygen(c *cli.Context) error {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return wrapError(err, "generating key")
+	}
+	privKeyData, err := x509.MarshalECPrivateKey(privKey)
+	if err != nil {
+		return wrapError(err, "encoding private key")
+	}
+	res, err := newAPIKeyInfo(&privKey.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	path := c.String("out")
+	if path == "" {
+		path, err = secret.DefaultPath(c.GlobalString("profile"))
+		if err != nil {
+			return wrapError(err, "resolving secret file path")
+		}
+	}
+	// Do not lose previously generated key
+	if _, err = os.Stat(path); err == nil {
+		return usageError("Secret file %s already exists", path)
+	}
+	if c.Bool("no-encrypt") {
+		fmt.Fprintf(os.Stderr, "Warning: API secret is stored unencrypted in %s, anyone able to read the file can use it\n", path)
+		err = secret.Plain(privKeyData).Save(path)
+	} else {
+		err = storeSecret(privKeyData, path)
+	}
+	if err != nil {
+		return err
+	}
+	res.APISecretFile = path
+	return Response(res)
+}
+
+// apiPubKey command handler
+func apiPubKey(c *cli.Context) error {
+	privKey, err := clients.LoadAPISecret(c)
+	if err != nil {
+		return err
+	}
+	res, err := newAPIKeyInfo(&privKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	return Response(res)
+}
+
+// newAPIKeyInfo with public key encoded as hex and PEM of DER (PKIX) form
+func newAPIKeyInfo(pubKey *ecdsa.PublicKey) (*APIKeyInfo, error) {
+	pubKeyData, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return nil, wrapError(err, "encoding public key")
+	}
+	return &APIKeyInfo{
+		PublicKey:    hex.EncodeToString(pubKeyData),
+		PublicKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubKeyData})),
+	}, nil
+}
+
+// APIKeyInfo descriptor
+type APIKeyInfo struct {
+	PublicKey     string `json:"public_key"`
+	PublicKeyPEM  string `json:"public_key_pem"`
+	APISecretFile string `json:"api_secret_file,omitempty"`
 }
 
 type RemoteNode struct {
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+	"github.com/xenaex/daccs-cli/secret"
+)
+
+func TestAPILimits(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+
+	stdout, _, err := e.run("api", "limits")
+	require.NoError(t, err)
+	var res clients.Limits
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "0.001", res.MinChannelCapacity.String())
+	assert.Equal(t, "0.00001", res.MinPaymentAmount.String())
+	assert.Equal(t, "1", res.ChannelReserveMultiplier.String())
+
+	e.rest.Errors["Limits"] = &clients.APIError{StatusCode: 503, Method: "GET", Path: "limits"}
+	_, _, err = e.run("api", "limits")
+	require.Error(t, err)
+	assert.Equal(t, 4, ExitCode(err))
+}
+
+func TestAPIAccounts(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.rest.AccountList = append(e.rest.AccountList, &clients.Account{ID: 2, Kind: "spot", Currency: "BTC"})
+
+	stdout, _, err := e.run("api", "accounts")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"id":1,"kind":"margin","currency":"BTC"},{"id":2,"kind":"spot","currency":"BTC"}]`, stdout)
+
+	e.rest.Errors["Accounts"] = &clients.APIError{StatusCode: 401, Method: "GET", Path: "accounts"}
+	_, _, err = e.run("api", "accounts")
+	require.Error(t, err)
+	assert.Equal(t, 3, ExitCode(err))
+}
+
+func TestAPIBalance(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.rest.Balances[1] = &clients.AccountBalance{AccountID: 1, Currency: "BTC", Total: btc("0.5"), Available: btc("0.4")}
+
+	stdout, _, err := e.run("api", "balance", "--account", "1")
+	require.NoError(t, err)
+	var res clients.AccountBalance
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "0.5", res.Total.String())
+	assert.Equal(t, "0.4", res.Available.String())
+
+	_, _, err = e.run("api", "balance")
+	require.Error(t, err)
+	assert.Equal(t, "Invalid account", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+	_, _, err = e.run("api", "balance", "--account", "9")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "on getting account 9 balance")
+	assert.Equal(t, 1, ExitCode(err))
+}
+
+func TestAPIDeposits(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	for i, id := range []string{"dep-1", "dep-2", "dep-3"} {
+		e.rest.DepositList = append(e.rest.DepositList, &clients.Deposit{AccountID: 1, ExternalID: id,
+			Amount: btc("0.001"), Status: clients.InvoiceSettled, CreatedAt: time.Unix(int64(i), 0).UTC()})
+	}
+	e.rest.DepositList = append(e.rest.DepositList, &clients.Deposit{AccountID: 2, ExternalID: "other"})
+
+	stdout, _, err := e.run("api", "deposits", "--account", "1")
+	require.NoError(t, err)
+	var res []clients.Deposit
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	require.Len(t, res, 3)
+	assert.Equal(t, "dep-3", res[0].ExternalID)
+	assert.Equal(t, "dep-1", res[2].ExternalID)
+
+	// Paging
+	stdout, _, err = e.run("api", "deposits", "--account", "1", "--offset", "1", "--limit", "1")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	require.Len(t, res, 1)
+	assert.Equal(t, "dep-2", res[0].ExternalID)
+
+	stdout, _, err = e.run("api", "deposits", "--account", "1", "--offset", "5")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, stdout)
+
+	_, _, err = e.run("api", "deposits")
+	require.Error(t, err)
+	assert.Equal(t, 2, ExitCode(err))
+}
+
+func TestAPIKeygen(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	path := filepath.Join(filepath.Dir(e.journal), "secrets", "test.json")
+	defer stubPasswords("passphrase", "passphrase")()
+
+	// Private key is encrypted by default
+	stdout, stderr, err := e.run("api", "keygen", "--out", path)
+	require.NoError(t, err)
+	assert.Equal(t, "", stderr)
+	var res APIKeyInfo
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, path, res.APISecretFile)
+	assert.Contains(t, res.PublicKeyPEM, "BEGIN PUBLIC KEY")
+	f, err := secret.Load(path)
+	require.NoError(t, err)
+	assert.True(t, f.Encrypted())
+	privKeyData, err := f.Decrypt([]byte("passphrase"))
+	require.NoError(t, err)
+
+	// Existing key is not overwritten
+	_, _, err = e.run("api", "keygen", "--out", path)
+	require.Error(t, err)
+	assert.Equal(t, "Secret file "+path+" already exists", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+
+	// Public key of the stored secret is printed
+	stdout, _, err = e.run("--api-secret", hex.EncodeToString(privKeyData), "api", "pubkey")
+	require.NoError(t, err)
+	var pub APIKeyInfo
+	require.NoError(t, json.Unmarshal([]byte(stdout), &pub))
+	assert.Equal(t, res.PublicKey, pub.PublicKey)
+	assert.Equal(t, "", pub.APISecretFile)
+}
+
+func TestAPIKeygenPassphrase(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	path := filepath.Join(filepath.Dir(e.journal), "test.json")
+
+	defer stubPasswords("passphrase", "other")()
+	_, _, err := e.run("api", "keygen", "--out", path)
+	require.Error(t, err)
+	assert.Equal(t, "Passphrases do not match", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+
+	defer stubPasswords("")()
+	_, _, err = e.run("api", "keygen", "--out", path)
+	require.Error(t, err)
+	assert.Equal(t, "Empty passphrase is not allowed", err.Error())
+	_, err = ioutil.ReadFile(path)
+	assert.Error(t, err)
+}
+
+func TestAPIKeygenNoEncrypt(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	path := filepath.Join(filepath.Dir(e.journal), "test.json")
+	defer stubPasswords()()
+
+	stdout, stderr, err := e.run("api", "keygen", "--no-encrypt", "--out", path)
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "Warning: API secret is stored unencrypted in "+path)
+	var res APIKeyInfo
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	f, err := secret.Load(path)
+	require.NoError(t, err)
+	assert.False(t, f.Encrypted())
+
+	// Plaintext secret is read without passphrase prompt
+	stdout, _, err = e.run("--api-secret-file", path, "api", "pubkey")
+	require.NoError(t, err)
+	var pub APIKeyInfo
+	require.NoError(t, json.Unmarshal([]byte(stdout), &pub))
+	assert.Equal(t, res.PublicKey, pub.PublicKey)
+}
+
+func TestAPIPubKeyErrors(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+
+	_, _, err := e.run("api", "pubkey")
+	require.Error(t, err)
+	assert.Equal(t, "api-secret is not specified", err.Error())
+	_, _, err = e.run("--api-secret", "zz", "api", "pubkey")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "on decoding api-secret")
+}
+
+// stubPasswords returned by readPassword in order, the returned func restores it
+func stubPasswords(passwords ...string) func() {
+	saved := readPassword
+	readPassword = func(prompt string) ([]byte, error) {
+		if len(passwords) == 0 {
+			return nil, errors.New("unexpected passphrase prompt " + prompt)
+		}
+		p := passwords[0]
+		passwords = passwords[1:]
+		return []byte(p), nil
+	}
+	return func() { readPassword = saved }
+}
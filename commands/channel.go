@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/urfave/cli"
@@ -12,10 +15,35 @@ import (
 )
 
 const (
-	channelFundingPrecision = int32(3)
-	satoshiPrecision        = int32(8)
+	defaultFundingTimeout = time.Hour
+	defaultOpenTimeout    = 2 * time.Hour
+	defaultCloseTimeout   = 2 * time.Hour
+	// closedLookupLimit of recently closed channels to find the closed one in
+	closedLookupLimit = 100
+	// defaultRequiredConfs of funding transaction, matches lnd bitcoin.defaultchanconfs
+	defaultRequiredConfs = 3
+	// blockInterval on average, used to estimate time-lock durations
+	blockInterval = 10 * time.Minute
+	// fundingPollInterval of wallet balance while waiting for deposit, catches confirmations
+	// beyond the first one which are not reported by transactions subscription
+	fundingPollInterval = 30 * time.Second
+	// defaultCloseParallel closes run concurrently with close all
+	defaultCloseParallel = 4
 )
 
+// closePendingTimeout of closing transaction broadcast, lnd reports pending close once it's done
+var closePendingTimeout = 5 * time.Minute
+
+// ChannelCloseResult of channel close all command per channel
+type ChannelCloseResult struct {
+	ID           uint64 `json:"id,omitempty"`
+	Node         string `json:"node"`
+	ChannelPoint string `json:"channel_point"`
+	Status       string `json:"status"`
+	ClosingTxid  string `json:"closing_txid,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
 // Channel commands definition
 var Channel = cli.Command{
 	Name:    "channel",
@@ -35,15 +63,40 @@ var Channel = cli.Command{
 				cli.StringFlag{Name: "node-id"},
 				cli.StringFlag{Name: "node-pubkey"},
 				cli.StringFlag{Name: "capacity"},
+				cli.Int64Flag{Name: "sat-per-byte", Usage: "Fee rate of funding transaction"},
+				cli.IntFlag{Name: "target-conf", Usage: "Blocks to confirm funding transaction within, its fee rate is estimated for"},
+				cli.StringFlag{Name: "push-amount", Usage: "Amount to push to the remote node on open"},
+				cli.StringFlag{Name: "min-htlc", Usage: "Min HTLC amount the local node accepts"},
+				cli.UintFlag{Name: "remote-csv-delay", Value: clients.DefaultCSVDelay, Usage: "Blocks the remote node funds are delayed for on force close"},
+				cli.BoolFlag{Name: "private", Usage: "Do not announce the channel to the network (default)"},
+				cli.BoolFlag{Name: "public", Usage: "Announce the channel to the network"},
+				cli.IntFlag{Name: "min-confs", Value: 1, Usage: "Confirmations of wallet outputs to fund the channel with, unconfirmed ones are spent if 0"},
+				cli.BoolFlag{Name: "wait-funding", Usage: "Wait for deposit to the wallet if its balance is short of capacity, emitting progress events as JSON lines to stderr"},
+				cli.DurationFlag{Name: "funding-timeout", Value: defaultFundingTimeout, Usage: "Time to wait for deposit with wait-funding"},
+				cli.BoolFlag{Name: "wait", Usage: "Wait until the channel is open, emitting progress events as JSON lines to stderr"},
+				cli.IntFlag{Name: "required-confs", Value: defaultRequiredConfs, Usage: "Confirmations of funding transaction lnd requires to open the channel, confirmations seen are reported against it with wait"},
+				cli.DurationFlag{Name: "wait-timeout", Value: defaultOpenTimeout, Usage: "Time to wait for the channel to open with wait, it's left pending afterwards"},
 			},
 		},
 		{
 			Name:   "close",
-			Usage:  "Close an existing channel identified by id or channel-point",
+			Usage:  "Close an existing channel identified by id or channel-point, or all channels matching filters",
 			Action: channelClose,
 			Flags: []cli.Flag{
 				cli.Uint64Flag{Name: "id"},
 				cli.StringFlag{Name: "channel-point"},
+				cli.BoolFlag{Name: "all", Usage: "Close all active and inactive channels matching filters"},
+				cli.StringFlag{Name: "node-pubkey", Usage: "Close all channels with the node only"},
+				cli.BoolFlag{Name: "inactive-only", Usage: "Close all inactive channels only"},
+				cli.BoolFlag{Name: "xena-only", Usage: "Close all channels with Xena lnd nodes only"},
+				cli.IntFlag{Name: "parallel", Value: defaultCloseParallel, Usage: "Closes run concurrently with all"},
+				cli.BoolFlag{Name: "force", Usage: "Close unilaterally, local funds are time-locked for the channel CSV delay"},
+				cli.Int64Flag{Name: "sat-per-byte", Usage: "Fee rate of cooperative closing transaction"},
+				cli.IntFlag{Name: "target-conf", Usage: "Blocks to confirm cooperative closing transaction within, its fee rate is estimated for"},
+				cli.StringFlag{Name: "delivery-address", Usage: "Address to pay local funds to on cooperative close, not supported by the linked lnd version"},
+				cli.BoolFlag{Name: "yes", Usage: "Do not ask for confirmation of force close"},
+				cli.BoolFlag{Name: "wait", Usage: "Wait until the closing transaction confirms, emitting progress events as JSON lines to stderr"},
+				cli.DurationFlag{Name: "wait-timeout", Value: defaultCloseTimeout, Usage: "Time to wait for the channel to close with wait, it's left pending afterwards"},
 			},
 		},
 		{
@@ -60,16 +113,15 @@ var Channel = cli.Command{
 
 // channelList command handler
 func channelList(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
 	list, err := lncli.Channels()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting channels list", err)
+		return wrapError(err, "getting channels list")
 	}
-	ResponseJSON(list)
-	return nil
+	return Response(list)
 }
 
 // channelOpen command handler
@@ -84,20 +136,20 @@ func channelOpen(c *cli.Context) error {
 	nodeID := c.String("node-id")
 	nodePubKey := c.String("node-pubkey")
 	if nodeID == "" && nodePubKey == "" {
-		return fmt.Errorf("Either node-id or node-pubkey required")
+		return usageError("Either node-id or node-pubkey required")
 	}
 
 	// Parse capacity
-	capacity, err := decimal.NewFromString(c.String("capacity"))
+	capacity, err := parseAmount(c, "capacity", channelFundingPrecision)
 	if err != nil {
-		return fmt.Errorf("Invalid capacity value")
+		return err
 	}
 
-	restcli, err := clients.NewRestClient(c)
+	restcli, err := Clients.Rest(c)
 	if err != nil {
 		return err
 	}
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
@@ -105,16 +157,23 @@ func channelOpen(c *cli.Context) error {
 	// Get limits from API and validate capacity
 	limits, err := restcli.Limits()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting Limits", err)
+		return wrapError(err, "getting Limits")
+	}
+	if err = checkChannelCapacity(capacity, limits); err != nil {
+		return err
+	}
+	params, err := openChannelParams(c)
+	if err != nil {
+		return err
 	}
-	if capacity.LessThan(limits.MinChannelCapacity) {
-		return fmt.Errorf("Capacity should be greater than or equal %s", limits.MinChannelCapacity)
+	if err = checkOpenParams(params, capacity, limits); err != nil {
+		return err
 	}
 
 	// Get remote nodes and find the provided one
 	remoteNodes, err := restcli.RemoteNodes()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting RemoteNodes", err)
+		return wrapError(err, "getting RemoteNodes")
 	}
 	var remoteNode *clients.Node
 	for _, n := range remoteNodes {
@@ -128,38 +187,54 @@ func channelOpen(c *cli.Context) error {
 		if p == "" {
 			p = nodePubKey
 		}
-		return fmt.Errorf("Unknown remote node %s to open channel with", p)
+		return usageError("Unknown remote node %s to open channel with", p)
 	}
 
 	// Ensure lnd node registration
 	pubKey, err := lncli.NodePubKey()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting NodePubKey", err)
+		return wrapError(err, "getting NodePubKey")
 	}
 	err = restcli.RegisterNode(pubKey)
 	if err != nil {
-		return fmt.Errorf("Error %s on registering node", err)
+		return wrapError(err, "registering node")
 	}
 
 	// Ensure local node balance
 	nodeBalance, err := lncli.Balance()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting node balance", err)
+		return wrapError(err, "getting node balance")
 	}
 	if nodeBalance.LessThan(capacity) {
 		// Get address for deposit
 		addr, err := lncli.FundingAddress()
 		if err != nil {
-			return fmt.Errorf("Error %s on getting LND wallet deposit address", err)
+			return wrapError(err, "getting LND wallet deposit address")
+		}
+		if !c.Bool("wait-funding") {
+			details := struct {
+				Balance        decimal.Decimal `json:"balance" unit:"btc"`
+				Capacity       decimal.Decimal `json:"capacity" unit:"btc"`
+				Deposit        decimal.Decimal `json:"deposit" unit:"btc"`
+				DepositAddress string          `json:"deposit_address"`
+			}{nodeBalance, capacity, capacity.Sub(nodeBalance), addr}
+			return newError(CodeInsufficientFunds, nil, details,
+				"Insufficient LND wallet funds (%s) to open channel for %s. Please deposit at least %s to %s",
+				formatAmount(nodeBalance), formatAmount(capacity), formatAmount(capacity.Sub(nodeBalance)), addr)
+		}
+		minConfs := c.Int("min-confs")
+		if minConfs < 1 {
+			return usageError("min-confs should be positive")
+		}
+		if err = waitFunding(lncli, addr, capacity, nodeBalance, int32(minConfs), c.Duration("funding-timeout")); err != nil {
+			return err
 		}
-		return fmt.Errorf("Insufficient LND wallet funds (%s) to open channel for %s. Please deposit at least %s to %s",
-			nodeBalance, capacity, capacity.Sub(nodeBalance), addr)
 	}
 
 	// Ensure lnd node connection
 	connectedTo, err := lncli.Peers()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting LND connected peers", err)
+		return wrapError(err, "getting LND connected peers")
 	}
 	connected := false
 	for _, p := range connectedTo {
@@ -171,26 +246,57 @@ func channelOpen(c *cli.Context) error {
 	if !connected {
 		err = lncli.Connect(remoteNode.Address)
 		if err != nil {
-			return fmt.Errorf("Error %s on connecting to %s", err, remoteNode.Address)
+			return wrapError(err, fmt.Sprintf("connecting to %s", remoteNode.Address))
 		}
 	}
 
 	// Open channel on each connection and aggregate results
 	respChan := make(chan *clients.OpenChannelResult)
-	defer close(respChan)
+	done := make(chan struct{})
+	defer close(done)
 
-	err = lncli.OpenChannel(remoteNode.Address, capacity, respChan)
+	err = lncli.OpenChannel(remoteNode.Address, capacity, params, respChan, done)
 	if err != nil {
-		ResponseError(Error{Error: fmt.Sprintf("Failed to open channel with %s: %s", remoteNode.Address, err)})
-		os.Exit(1)
+		return wrapError(err, fmt.Sprintf("opening channel with %s", remoteNode.Address))
 	}
 	r := <-respChan
 	if r.Error != nil {
-		ResponseError(Error{Error: fmt.Sprintf("Failed to open channel with %s: %s", r.Node, r.Error)})
-		os.Exit(1)
+		return wrapError(r.Error, fmt.Sprintf("opening channel with %s", r.Node))
 	}
-	ResponseJSON(r.ChannelStatus)
-	return nil
+	if !c.Bool("wait") {
+		return Response(r.ChannelStatus)
+	}
+	requiredConfs := c.Int("required-confs")
+	if requiredConfs < 1 {
+		return usageError("required-confs should be positive")
+	}
+	return waitOpen(lncli, respChan, r.ChannelStatus, int32(requiredConfs), c.Duration("wait-timeout"))
+}
+
+// openChannelParams parsed from channel open flags
+func openChannelParams(c *cli.Context) (clients.OpenChannelParams, error) {
+	if c.Bool("private") && c.Bool("public") {
+		return clients.OpenChannelParams{}, usageError("Either private or public can be specified")
+	}
+	params := clients.OpenChannelParams{
+		SatPerByte:     c.Int64("sat-per-byte"),
+		TargetConf:     int32(c.Int("target-conf")),
+		RemoteCsvDelay: uint32(c.Uint("remote-csv-delay")),
+		Private:        !c.Bool("public"),
+		MinConfs:       int32(c.Int("min-confs")),
+	}
+	var err error
+	if c.String("push-amount") != "" {
+		if params.PushAmount, err = parseAmount(c, "push-amount", satoshiPrecision); err != nil {
+			return params, err
+		}
+	}
+	if c.String("min-htlc") != "" {
+		if params.MinHtlc, err = parseAmount(c, "min-htlc", millisatoshiPrecision); err != nil {
+			return params, err
+		}
+	}
+	return params, nil
 }
 
 // channelClose command handler
@@ -201,40 +307,425 @@ func channelClose(c *cli.Context) error {
 		return nil
 	}
 
+	params, err := closeChannelParams(c)
+	if err != nil {
+		return err
+	}
+	if c.Bool("all") {
+		return channelCloseAll(c, params)
+	}
+	for _, name := range []string{"node-pubkey", "inactive-only", "xena-only", "parallel"} {
+		if c.IsSet(name) {
+			return usageError("%s is applicable to close all only", name)
+		}
+	}
+
 	// Parse and validate id or channel point
 	chanID := c.Uint64("id")
 	chanPoint := c.String("channel-point")
 	if chanID == 0 && chanPoint == "" {
-		return fmt.Errorf("Either id or channel-point required")
+		return usageError("Either id, channel-point or all required")
+	}
+	cid := chanPoint
+	if cid == "" {
+		cid = strconv.FormatUint(chanID, 10)
+	}
+
+	lncli, err := Clients.Lnd(c, true)
+	if err != nil {
+		return err
+	}
+	if params.Force && !c.Bool("yes") {
+		list, err := lncli.Channels()
+		if err != nil {
+			return wrapError(err, "getting channels list")
+		}
+		var channel *clients.ChannelStatus
+		for _, ch := range list {
+			if (chanID != 0 && ch.ID == chanID) || (chanPoint != "" && ch.ChannelPoint == chanPoint) {
+				channel = ch
+				break
+			}
+		}
+		if channel == nil {
+			return usageError("Channel %s not found", cid)
+		}
+		if err = confirmForceClose([]*clients.ChannelStatus{channel}); err != nil {
+			return err
+		}
 	}
 
 	// Close channel
-	lncli, err := clients.NewLndClient(c, true)
+	respChan := make(chan *clients.CloseChannelResult)
+	done := make(chan struct{})
+	defer close(done)
+	cs, err := closePending(lncli, chanID, chanPoint, params, respChan, done)
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("closing channel %s", cid))
+	}
+	if !c.Bool("wait") {
+		return Response(cs)
+	}
+	return waitClose(lncli, respChan, *cs, c.Duration("wait-timeout"))
+}
+
+// channelCloseAll closes channels matching filters concurrently and responds result per channel
+func channelCloseAll(c *cli.Context, params clients.CloseChannelParams) error {
+	if c.Uint64("id") != 0 || c.String("channel-point") != "" {
+		return usageError("Either id, channel-point or all can be specified")
+	}
+	if c.Bool("wait") {
+		return usageError("wait is not applicable to close all")
+	}
+	parallel := c.Int("parallel")
+	if parallel < 1 {
+		return usageError("parallel should be positive")
+	}
+
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
-	cs, err := lncli.CloseChannel(chanID, chanPoint)
+	list, err := lncli.Channels()
 	if err != nil {
-		cid := chanPoint
-		if cid == "" {
-			cid = strconv.FormatUint(chanID, 10)
+		return wrapError(err, "getting channels list")
+	}
+	var addrs []string
+	if c.Bool("xena-only") {
+		restcli, err := Clients.Rest(c)
+		if err != nil {
+			return err
+		}
+		if addrs, err = restcli.RemoteAddresses(); err != nil {
+			return wrapError(err, "getting RemoteAddresses")
+		}
+	}
+
+	// Filter channels, pending ones can't be closed
+	nodePubKey := c.String("node-pubkey")
+	channels := []*clients.ChannelStatus{}
+	for _, ch := range list {
+		switch {
+		case ch.Status != "active" && ch.Status != "inactive":
+		case c.Bool("inactive-only") && ch.Status != "inactive":
+		case nodePubKey != "" && ch.Node != nodePubKey:
+		case c.Bool("xena-only") && !isXenaNode(addrs, ch.Node):
+		default:
+			channels = append(channels, ch)
+		}
+	}
+	if len(channels) == 0 {
+		return Response([]ChannelCloseResult{})
+	}
+	if params.Force && !c.Bool("yes") {
+		if err = confirmForceClose(channels); err != nil {
+			return err
+		}
+	}
+
+	// Close channels concurrently bounded by parallel
+	results := make([]ChannelCloseResult, len(channels))
+	sem := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+	for i, ch := range channels {
+		wg.Add(1)
+		go func(i int, ch *clients.ChannelStatus) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res := ChannelCloseResult{ID: ch.ID, Node: ch.Node, ChannelPoint: ch.ChannelPoint, Status: ch.Status}
+			respChan := make(chan *clients.CloseChannelResult)
+			done := make(chan struct{})
+			defer close(done)
+			cs, err := closePending(lncli, 0, ch.ChannelPoint, params, respChan, done)
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Status = cs.Status
+				res.ClosingTxid = cs.ClosingTxid
+			}
+			results[i] = res
+		}(i, ch)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
 		}
-		return fmt.Errorf("Error %s on closing channel %s", err, cid)
 	}
-	ResponseJSON(cs)
+	if err := Response(results); err != nil {
+		return err
+	}
+	// Partial failure exits with the generic error code, per channel errors are in the results
+	if failed > 0 {
+		return newError(CodeError, nil, nil, "Closing %d of %d channels failed", failed, len(results))
+	}
 	return nil
 }
 
+// closeChannelParams parsed from channel close flags
+func closeChannelParams(c *cli.Context) (clients.CloseChannelParams, error) {
+	params := clients.CloseChannelParams{
+		Force:      c.Bool("force"),
+		SatPerByte: c.Int64("sat-per-byte"),
+		TargetConf: int32(c.Int("target-conf")),
+	}
+	// Linked lnrpc CloseChannelRequest has no delivery address, local funds are paid to the LND wallet
+	if c.String("delivery-address") != "" {
+		return params, usageError("delivery-address is not supported by the linked lnd version, local funds are paid to the LND wallet")
+	}
+	if params.SatPerByte < 0 {
+		return params, usageError("sat-per-byte should not be negative")
+	}
+	if params.TargetConf < 0 {
+		return params, usageError("target-conf should not be negative")
+	}
+	if params.SatPerByte != 0 && params.TargetConf != 0 {
+		return params, usageError("Either sat-per-byte or target-conf can be specified")
+	}
+	if params.Force && (params.SatPerByte != 0 || params.TargetConf != 0) {
+		return params, usageError("sat-per-byte and target-conf are not applicable to force close")
+	}
+	return params, nil
+}
+
+// closePending starts closing the channel and returns it once the closing transaction is broadcast,
+// further updates are sent to out until done is closed
+func closePending(lncli clients.LndClient, chanID uint64, chanPoint string, params clients.CloseChannelParams,
+	out chan *clients.CloseChannelResult, done chan struct{}) (*clients.ChannelStatus, error) {
+	if err := lncli.CloseChannel(chanID, chanPoint, params, out, done); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(closePendingTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-out:
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		return &r.ChannelStatus, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("closing transaction was not broadcast within %s", closePendingTimeout)
+	}
+}
+
+// confirmForceClose of channels asking user on stderr, time-lock of local funds is shown
+func confirmForceClose(channels []*clients.ChannelStatus) error {
+	for _, channel := range channels {
+		csvDelay := channel.CsvDelay
+		if csvDelay == 0 {
+			csvDelay = clients.DefaultCSVDelay
+		}
+		fmt.Fprintf(os.Stderr, "Force closing channel %s time-locks local balance %s for %d blocks (~%s) after closing transaction confirms.\n",
+			channel.ChannelPoint, formatAmount(channel.LocalBalance), csvDelay, time.Duration(csvDelay)*blockInterval)
+	}
+	fmt.Fprint(os.Stderr, "Proceed? [y/N]: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return usageError("Force close is not confirmed, use --yes to skip confirmation")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return usageError("Force close is not confirmed")
+}
+
 // channelHistory command handler
 func channelHistory(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
 	closed, err := lncli.ClosedChannels(c.Int("offset"), c.Int("limit"))
 	if err != nil {
-		return fmt.Errorf("Error %s on getting closed channels list", err)
+		return wrapError(err, "getting closed channels list")
+	}
+	return Response(closed)
+}
+
+// waitFunding of the wallet until its balance confirmed by minConfs covers capacity, emitting progress events
+func waitFunding(lncli clients.LndClient, addr string, capacity, balance decimal.Decimal, minConfs int32, timeout time.Duration) error {
+	type fundingStatus struct {
+		Address  string          `json:"address"`
+		Balance  decimal.Decimal `json:"balance" unit:"btc"`
+		Capacity decimal.Decimal `json:"capacity" unit:"btc"`
+		Deposit  decimal.Decimal `json:"deposit" unit:"btc"`
+		MinConfs int32           `json:"min_confs"`
+	}
+	status := func() fundingStatus {
+		deposit := capacity.Sub(balance)
+		if deposit.Sign() < 0 {
+			deposit = decimal.Zero
+		}
+		return fundingStatus{addr, balance, capacity, deposit, minConfs}
+	}
+	Event("awaiting_deposit", status())
+
+	updates := make(chan *clients.TransactionUpdate)
+	done := make(chan struct{})
+	defer close(done)
+	if err := lncli.SubscribeTransactions(updates, done); err != nil {
+		return wrapError(err, "subscribing to wallet transactions")
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(fundingPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case u := <-updates:
+			if u.Error != nil {
+				return wrapError(u.Error, "watching wallet transactions")
+			}
+			// Only incoming transactions to the deposit address fund the wallet
+			if u.Transaction.Amount.Sign() <= 0 || !hasAddress(u.Transaction.DestAddresses, addr) {
+				continue
+			}
+			Event("deposit", u.Transaction)
+		case <-ticker.C:
+		case <-timer.C:
+			return newError(CodeInsufficientFunds, nil, status(), "Deposit of %s to %s was not confirmed within %s",
+				formatAmount(capacity.Sub(balance)), addr, timeout)
+		}
+		confirmed, err := lncli.ConfirmedBalance(minConfs)
+		if err != nil {
+			return wrapError(err, "getting node balance")
+		}
+		if !confirmed.Equal(balance) {
+			balance = confirmed
+			Event("balance", status())
+		}
+		if !balance.LessThan(capacity) {
+			Event("funded", status())
+			return nil
+		}
+	}
+}
+
+// hasAddress checks if addr is one of transaction destination addresses
+func hasAddress(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// waitOpen of the pending channel until its funding transaction is confirmed, emitting progress events.
+// The channel is responded pending if timeout elapses earlier
+func waitOpen(lncli clients.LndClient, updates chan *clients.OpenChannelResult, channel clients.ChannelStatus, requiredConfs int32, timeout time.Duration) error {
+	type openStatus struct {
+		ChannelPoint  string `json:"channel_point"`
+		Confirmations int32  `json:"confirmations"`
+		RequiredConfs int32  `json:"required_confs"`
+	}
+	confs := int32(0)
+	confirmation := func(seen int32) {
+		if seen > confs {
+			confs = seen
+			Event("confirmation", openStatus{channel.ChannelPoint, confs, requiredConfs})
+		}
+	}
+	// Linked lnrpc has no confirmation updates, they are polled from the funding transaction
+	txid := strings.Split(channel.ChannelPoint, ":")[0]
+	pollConfirmations := func() error {
+		tx, err := lncli.Transaction(txid)
+		if err != nil {
+			return wrapError(err, "getting funding transaction")
+		}
+		if tx != nil {
+			confirmation(tx.NumConfirmations)
+		}
+		return nil
+	}
+	Event("pending", channel)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(fundingPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case r := <-updates:
+			if r.Error != nil {
+				return wrapError(r.Error, fmt.Sprintf("waiting for channel %s to open", channel.ChannelPoint))
+			}
+			if r.Status == "pending_open" {
+				continue
+			}
+			// Confirmations the channel is open with
+			if err := pollConfirmations(); err != nil {
+				return err
+			}
+			Event("open", r.ChannelStatus)
+			return Response(r.ChannelStatus)
+		case <-ticker.C:
+			if err := pollConfirmations(); err != nil {
+				return err
+			}
+		case <-timer.C:
+			Event("timeout", openStatus{channel.ChannelPoint, confs, requiredConfs})
+			return Response(channel)
+		}
+	}
+}
+
+// waitClose of the closing channel until its closing transaction is confirmed, emitting progress events.
+// The channel is responded pending if timeout elapses earlier
+func waitClose(lncli clients.LndClient, updates chan *clients.CloseChannelResult, channel clients.ChannelStatus, timeout time.Duration) error {
+	type closeStatus struct {
+		ChannelPoint  string `json:"channel_point"`
+		ClosingTxid   string `json:"closing_txid"`
+		Confirmations int32  `json:"confirmations"`
+	}
+	confs := int32(0)
+	Event("close_pending", channel)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(fundingPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case r := <-updates:
+			if r.Error != nil {
+				return wrapError(r.Error, fmt.Sprintf("waiting for channel %s to close", channel.ChannelPoint))
+			}
+			if r.Status != "closed" {
+				continue
+			}
+			if confs == 0 {
+				Event("confirmation", closeStatus{channel.ChannelPoint, r.ClosingTxid, 1})
+			}
+			// Look up the closed channel for its settled balance
+			closed, err := lncli.ClosedChannels(0, closedLookupLimit)
+			if err != nil {
+				return wrapError(err, "getting closed channels list")
+			}
+			for _, cc := range closed {
+				if cc.ChannelPoint == channel.ChannelPoint {
+					Event("closed", cc)
+					return Response(cc)
+				}
+			}
+			Event("closed", r.ChannelStatus)
+			return Response(r.ChannelStatus)
+		case <-ticker.C:
+			tx, err := lncli.Transaction(channel.ClosingTxid)
+			if err != nil {
+				return wrapError(err, "getting closing transaction")
+			}
+			if tx != nil && tx.NumConfirmations > confs {
+				confs = tx.NumConfirmations
+				Event("confirmation", closeStatus{channel.ChannelPoint, channel.ClosingTxid, confs})
+			}
+		case <-timer.C:
+			Event("timeout", closeStatus{channel.ChannelPoint, channel.ClosingTxid, confs})
+			return Response(channel)
+		}
 	}
-	ResponseJSON(closed)
-	return nil
 }
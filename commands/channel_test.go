@@ -0,0 +1,484 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+	"github.com/xenaex/daccs-cli/clients/fake"
+)
+
+func TestChannelList(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+
+	stdout, _, err := e.run("channel", "list")
+	require.NoError(t, err)
+	var res []clients.ChannelStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	require.Len(t, res, 3)
+	assert.Equal(t, "tx1:0", res[0].ChannelPoint)
+	assert.Equal(t, "0.01", res[0].LocalBalance.String())
+
+	e.lnd.Locked = true
+	stdout, _, err = e.run("channel", "list")
+	require.Error(t, err)
+	assert.Equal(t, "Error wallet is locked on unlocking lnd node", err.Error())
+	assert.Equal(t, 6, ExitCode(err))
+	assert.Equal(t, "", stdout)
+}
+
+func TestChannelOpen(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.WalletBalance = btc("0.1")
+
+	stdout, _, err := e.run("channel", "open", "--node-id", "xena-1", "--capacity", "0.01", "--push-amount", "1000sat")
+	require.NoError(t, err)
+	var res clients.ChannelStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, fake.RemotePubKey, res.Node)
+	assert.Equal(t, "pending_open", res.Status)
+	assert.Equal(t, "0.01", res.Capacity.String())
+	assert.Equal(t, "0.00001", res.RemoteBalance.String())
+
+	assert.Equal(t, []string{fake.LocalPubKey}, e.rest.RegisteredPubKeys)
+	assert.Equal(t, []string{e.rest.NodeList[0].Address}, e.lnd.PeerAddresses)
+	assert.Equal(t, "0.09", e.lnd.WalletBalance.String())
+	params := e.lnd.OpenParams[res.ChannelPoint]
+	assert.True(t, params.Private)
+	assert.Equal(t, uint32(clients.DefaultCSVDelay), params.RemoteCsvDelay)
+}
+
+func TestChannelOpenWait(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.WalletBalance = btc("0.1")
+	e.lnd.ConfirmOnOpen = true
+
+	stdout, stderr, err := e.run("channel", "open", "--node-pubkey", fake.RemotePubKey, "--capacity", "0.01", "--wait")
+	require.NoError(t, err)
+	var res clients.ChannelStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "active", res.Status)
+	assert.NotEqual(t, uint64(0), res.ID)
+	assert.NotEqual(t, "", res.ShortChannelID)
+	assert.Equal(t, []string{"pending", "confirmation", "open"}, eventNames(t, stderr))
+
+	// Confirmations are the ones of funding transaction, not the required ones
+	stdout, stderr, err = e.run("channel", "open", "--node-pubkey", fake.RemotePubKey, "--capacity", "0.01", "--wait", "--required-confs", "6")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	require.Len(t, lines, 3)
+	var status struct {
+		Confirmations int32 `json:"confirmations"`
+		RequiredConfs int32 `json:"required_confs"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &status))
+	assert.Equal(t, int32(fake.FundingConfirmations), status.Confirmations)
+	assert.Equal(t, int32(6), status.RequiredConfs)
+
+	_, _, err = e.run("channel", "open", "--node-pubkey", fake.RemotePubKey, "--capacity", "0.01", "--wait", "--required-confs", "0")
+	require.Error(t, err)
+	assert.Equal(t, "required-confs should be positive", err.Error())
+
+	e.lnd.Errors["Transaction"] = errors.New("rpc error")
+	_, _, err = e.run("channel", "open", "--node-pubkey", fake.RemotePubKey, "--capacity", "0.01", "--wait")
+	require.Error(t, err)
+	assert.Equal(t, "Error rpc error on getting funding transaction", err.Error())
+}
+
+func TestChannelOpenWaitFunding(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.WalletBalance = btc("0.004")
+	e.lnd.IncomingTransactions = []clients.Transaction{
+		{TxID: "other", Amount: btc("0.5"), DestAddresses: []string{"bcrt1qotheraddress"}},
+		{TxID: "deposit", Amount: btc("0.006"), NumConfirmations: 1, DestAddresses: []string{e.lnd.DepositAddress}},
+	}
+
+	stdout, stderr, err := e.run("channel", "open", "--node-id", "xena-1", "--capacity", "0.01", "--wait-funding")
+	require.NoError(t, err)
+	var res clients.ChannelStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "pending_open", res.Status)
+	assert.Equal(t, "0.01", res.Capacity.String())
+	assert.Equal(t, []string{"awaiting_deposit", "deposit", "balance", "funded"}, eventNames(t, stderr))
+	assert.Contains(t, stderr, `"deposit":"0.006"`)
+	assert.Equal(t, "0", e.lnd.WalletBalance.String())
+
+	e.lnd.WalletBalance = btc("0.004")
+	stdout, stderr, err = e.run("channel", "open", "--node-id", "xena-1", "--capacity", "0.01", "--wait-funding", "--funding-timeout", "10ms")
+	require.Error(t, err)
+	assert.Equal(t, "Deposit of 0.006 to bcrt1qfakedepositaddress was not confirmed within 10ms", err.Error())
+	assert.Equal(t, 7, ExitCode(err))
+	assert.Equal(t, "", stdout)
+	assert.Equal(t, []string{"awaiting_deposit"}, eventNames(t, stderr))
+
+	_, _, err = e.run("channel", "open", "--node-id", "xena-1", "--capacity", "0.01", "--wait-funding", "--min-confs", "0")
+	require.Error(t, err)
+	assert.Equal(t, "min-confs should be positive", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+}
+
+func TestChannelOpenErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(e *testEnv)
+		global  []string
+		args    []string
+		exit    int
+		message string
+	}{
+		{
+			name:    "no node",
+			args:    []string{"--capacity", "0.01"},
+			exit:    2,
+			message: "Either node-id or node-pubkey required",
+		},
+		{
+			name:    "unknown node",
+			args:    []string{"--node-id", "xena-2", "--capacity", "0.01"},
+			exit:    2,
+			message: "Unknown remote node xena-2 to open channel with",
+		},
+		{
+			name:    "capacity precision",
+			global:  []string{"--unit", "sat"},
+			args:    []string{"--node-id", "xena-1", "--capacity", "150000"},
+			exit:    2,
+			message: "capacity 150000 sat should be a multiple of 100000 sat",
+		},
+		{
+			name:    "capacity below min",
+			setup:   func(e *testEnv) { e.rest.APILimits.MinChannelCapacity = btc("0.02") },
+			args:    []string{"--node-id", "xena-1", "--capacity", "0.01"},
+			exit:    2,
+			message: "Capacity should be greater than or equal 0.02",
+		},
+		{
+			name:    "both private and public",
+			args:    []string{"--node-id", "xena-1", "--capacity", "0.01", "--private", "--public"},
+			exit:    2,
+			message: "Either private or public can be specified",
+		},
+		{
+			name: "public not allowed",
+			setup: func(e *testEnv) {
+				allowed := false
+				e.rest.APILimits.ChannelOpen.PublicAllowed = &allowed
+			},
+			args:    []string{"--node-id", "xena-1", "--capacity", "0.01", "--public"},
+			exit:    2,
+			message: "Public channels are not allowed",
+		},
+		{
+			name:    "insufficient funds",
+			setup:   func(e *testEnv) { e.lnd.WalletBalance = btc("0.004") },
+			args:    []string{"--node-id", "xena-1", "--capacity", "0.01"},
+			exit:    7,
+			message: "Insufficient LND wallet funds (0.004) to open channel for 0.01. Please deposit at least 0.006 to bcrt1qfakedepositaddress",
+		},
+		{
+			name:    "insufficient funds in unit",
+			setup:   func(e *testEnv) { e.lnd.WalletBalance = btc("0.004") },
+			global:  []string{"--unit", "sat"},
+			args:    []string{"--node-id", "xena-1", "--capacity", "1000000"},
+			exit:    7,
+			message: "Insufficient LND wallet funds (400000 sat) to open channel for 1000000 sat",
+		},
+		{
+			name:    "wallet locked",
+			setup:   func(e *testEnv) { e.lnd.Locked = true },
+			args:    []string{"--node-id", "xena-1", "--capacity", "0.01"},
+			exit:    6,
+			message: "wallet is locked",
+		},
+		{
+			name: "api unavailable",
+			setup: func(e *testEnv) {
+				e.rest.Errors["Limits"] = &clients.APIError{StatusCode: 503, Method: "GET", Path: "limits"}
+			},
+			args:    []string{"--node-id", "xena-1", "--capacity", "0.01"},
+			exit:    4,
+			message: "on getting Limits",
+		},
+		{
+			name:    "open failed",
+			setup:   func(e *testEnv) { e.lnd.Errors["OpenChannel"] = errors.New("peer is not online") },
+			args:    []string{"--node-id", "xena-1", "--capacity", "0.01"},
+			exit:    1,
+			message: "Error peer is not online on opening channel with " + fake.RemotePubKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			defer e.close()
+			e.lnd.WalletBalance = btc("0.1")
+			if tt.setup != nil {
+				tt.setup(e)
+			}
+			args := append(append(tt.global, "channel", "open"), tt.args...)
+			stdout, _, err := e.run(args...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+			assert.Equal(t, tt.exit, ExitCode(err))
+			assert.Equal(t, "", stdout)
+			assert.Len(t, e.lnd.ChannelList, 0)
+		})
+	}
+}
+
+func TestChannelClose(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+
+	stdout, _, err := e.run("channel", "close", "--id", "1", "--target-conf", "6")
+	require.NoError(t, err)
+	var res clients.ChannelStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "waiting_close", res.Status)
+	assert.NotEqual(t, "", res.ClosingTxid)
+	assert.Equal(t, clients.CloseChannelParams{TargetConf: 6}, e.lnd.CloseParams["tx1:0"])
+
+	stdout, _, err = e.run("channel", "close", "--channel-point", "tx2:0", "--force", "--yes")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "pending_force_closing", res.Status)
+	assert.Equal(t, clients.CloseChannelParams{Force: true}, e.lnd.CloseParams["tx2:0"])
+}
+
+func TestChannelCloseWait(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+	e.lnd.ConfirmOnClose = true
+
+	stdout, stderr, err := e.run("--unit", "sat", "channel", "close", "--id", "1", "--wait")
+	require.NoError(t, err)
+	var res clients.ClosedChannel
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "tx1:0", res.ChannelPoint)
+	assert.Equal(t, "1000000", res.SettledBalance.String())
+	assert.Equal(t, "COOPERATIVE_CLOSE", res.CloseType)
+	assert.Equal(t, "0.01", e.lnd.WalletBalance.String())
+
+	assert.Equal(t, []string{"close_pending", "confirmation", "closed"}, eventNames(t, stderr))
+
+	// The channel is left pending close on timeout
+	e.lnd.ConfirmOnClose = false
+	stdout, stderr, err = e.run("channel", "close", "--id", "2", "--wait", "--wait-timeout", "10ms")
+	require.NoError(t, err)
+	var pending clients.ChannelStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &pending))
+	assert.Equal(t, "waiting_close", pending.Status)
+	assert.NotEqual(t, "", pending.ClosingTxid)
+	assert.Equal(t, []string{"close_pending", "timeout"}, eventNames(t, stderr))
+
+	_, _, err = e.run("channel", "close", "--all", "--wait")
+	require.Error(t, err)
+	assert.Equal(t, "wait is not applicable to close all", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+}
+
+func TestChannelCloseAll(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+
+	stdout, _, err := e.run("channel", "close", "--all", "--xena-only")
+	require.NoError(t, err)
+	var res []ChannelCloseResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	require.Len(t, res, 2)
+	for _, r := range res {
+		assert.Equal(t, fake.RemotePubKey, r.Node)
+		assert.Equal(t, "waiting_close", r.Status)
+		assert.Equal(t, "", r.Error)
+	}
+	assert.Len(t, e.lnd.CloseParams, 2)
+	assert.Equal(t, "active", e.lnd.ChannelList[2].Status)
+}
+
+func TestChannelCloseAllFailed(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+	e.lnd.Errors["CloseChannel"] = errors.New("channel is in use")
+
+	stdout, _, err := e.run("channel", "close", "--all", "--inactive-only")
+	require.Error(t, err)
+	assert.Equal(t, "Closing 1 of 1 channels failed", err.Error())
+	assert.Equal(t, 1, ExitCode(err))
+	// Results are responded regardless of the failure
+	var res []ChannelCloseResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	require.Len(t, res, 1)
+	assert.Equal(t, "tx2:0", res[0].ChannelPoint)
+	assert.Equal(t, "channel is in use", res[0].Error)
+}
+
+func TestChannelCloseTimeout(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+	e.lnd.HoldClose = true
+	saved := closePendingTimeout
+	closePendingTimeout = 10 * time.Millisecond
+	defer func() { closePendingTimeout = saved }()
+
+	stdout, _, err := e.run("channel", "close", "--all", "--xena-only")
+	require.Error(t, err)
+	assert.Equal(t, "Closing 2 of 2 channels failed", err.Error())
+	assert.Equal(t, 1, ExitCode(err))
+	var res []ChannelCloseResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	require.Len(t, res, 2)
+	for _, r := range res {
+		assert.Equal(t, "closing transaction was not broadcast within 10ms", r.Error)
+	}
+
+	_, _, err = e.run("channel", "close", "--id", "3")
+	require.Error(t, err)
+	assert.Equal(t, "Error closing transaction was not broadcast within 10ms on closing channel 3", err.Error())
+}
+
+func TestChannelCloseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(e *testEnv)
+		args    []string
+		exit    int
+		message string
+	}{
+		{
+			name:    "no channel",
+			args:    []string{"--force"},
+			exit:    2,
+			message: "Either id, channel-point or all required",
+		},
+		{
+			name:    "filter without all",
+			args:    []string{"--id", "1", "--xena-only"},
+			exit:    2,
+			message: "xena-only is applicable to close all only",
+		},
+		{
+			name:    "id with all",
+			args:    []string{"--id", "1", "--all"},
+			exit:    2,
+			message: "Either id, channel-point or all can be specified",
+		},
+		{
+			name:    "fee rate and target",
+			args:    []string{"--id", "1", "--sat-per-byte", "10", "--target-conf", "6"},
+			exit:    2,
+			message: "Either sat-per-byte or target-conf can be specified",
+		},
+		{
+			name:    "negative fee rate",
+			args:    []string{"--id", "1", "--sat-per-byte", "-1"},
+			exit:    2,
+			message: "sat-per-byte should not be negative",
+		},
+		{
+			name:    "negative target",
+			args:    []string{"--id", "1", "--target-conf", "-1"},
+			exit:    2,
+			message: "target-conf should not be negative",
+		},
+		{
+			name:    "fee rate of force close",
+			args:    []string{"--id", "1", "--force", "--sat-per-byte", "10"},
+			exit:    2,
+			message: "sat-per-byte and target-conf are not applicable to force close",
+		},
+		{
+			name:    "delivery address",
+			args:    []string{"--id", "1", "--delivery-address", "bcrt1qotheraddress"},
+			exit:    2,
+			message: "delivery-address is not supported by the linked lnd version, local funds are paid to the LND wallet",
+		},
+		{
+			name:    "invalid flag",
+			args:    []string{"--id", "one"},
+			exit:    2,
+			message: "invalid value",
+		},
+		{
+			name:    "unknown channel of force close",
+			args:    []string{"--id", "9", "--force"},
+			exit:    2,
+			message: "Channel 9 not found",
+		},
+		{
+			name:    "unknown channel",
+			args:    []string{"--id", "9"},
+			exit:    1,
+			message: "Error channel not found on closing channel 9",
+		},
+		{
+			name:    "wallet locked",
+			setup:   func(e *testEnv) { e.lnd.Locked = true },
+			args:    []string{"--id", "1"},
+			exit:    6,
+			message: "wallet is locked",
+		},
+		{
+			name:    "close failed",
+			setup:   func(e *testEnv) { e.lnd.Errors["CloseChannel"] = errors.New("channel is in use") },
+			args:    []string{"--channel-point", "tx1:0"},
+			exit:    1,
+			message: "Error channel is in use on closing channel tx1:0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			defer e.close()
+			e.lnd.ChannelList = testChannels()
+			if tt.setup != nil {
+				tt.setup(e)
+			}
+			stdout, _, err := e.run(append([]string{"channel", "close"}, tt.args...)...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+			assert.Equal(t, tt.exit, ExitCode(err))
+			assert.Equal(t, "", stdout)
+			assert.Len(t, e.lnd.CloseParams, 0)
+		})
+	}
+}
+
+// testChannels with Xena lnd node and some other node
+func testChannels() []*clients.ChannelStatus {
+	return []*clients.ChannelStatus{
+		{ID: 1, Node: fake.RemotePubKey, ChannelPoint: "tx1:0", Status: "active",
+			Capacity: btc("0.02"), LocalBalance: btc("0.01"), RemoteBalance: btc("0.01"), LocalReserved: btc("0.0002")},
+		{ID: 2, Node: fake.RemotePubKey, ChannelPoint: "tx2:0", Status: "inactive",
+			Capacity: btc("0.01"), LocalBalance: btc("0.01"), LocalReserved: btc("0.0001")},
+		{ID: 3, Node: "03cccc", ChannelPoint: "tx3:0", Status: "active",
+			Capacity: btc("0.01"), LocalBalance: btc("0.01"), LocalReserved: btc("0.0001")},
+	}
+}
+
+// eventNames of JSON lines events written to stderr
+func eventNames(t *testing.T, stderr string) []string {
+	names := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		var event struct {
+			Event string `json:"event"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		names = append(names, event.Event)
+	}
+	return names
+}
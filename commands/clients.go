@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+// ClientFactory creates clients for command handlers
+type ClientFactory struct {
+	// Rest creates Xena dAccs API client
+	Rest func(c *cli.Context) (clients.RestClient, error)
+	// Lnd creates local LND node client, unlocking the node if requested
+	Lnd func(c *cli.Context, unlocked bool) (clients.LndClient, error)
+}
+
+// Clients the command handlers resolve through.
+// Replace it to run commands against other implementations (e.g. clients/fake)
+var Clients = ClientFactory{
+	Rest: clients.NewRestClient,
+	Lnd:  clients.NewLndClient,
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/clients/fake"
+	"github.com/xenaex/daccs-cli/config"
+)
+
+// testEnv of command run against fake clients
+type testEnv struct {
+	t       *testing.T
+	rest    *fake.RestClient
+	lnd     *fake.LndClient
+	journal string
+	config  string
+}
+
+// newTestEnv with fake clients and journal in temp dir removed on test cleanup
+func newTestEnv(t *testing.T) *testEnv {
+	dir, err := ioutil.TempDir("", "daccs-cli-test")
+	require.NoError(t, err)
+	return &testEnv{
+		t:       t,
+		rest:    fake.NewRestClient(),
+		lnd:     fake.NewLndClient(),
+		journal: filepath.Join(dir, "journal.json"),
+		config:  filepath.Join(dir, "config.toml"),
+	}
+}
+
+// close removes journal temp dir
+func (e *testEnv) close() {
+	os.RemoveAll(filepath.Dir(e.journal))
+}
+
+// run command line with global flags and returns its stdout, stderr and error
+func (e *testEnv) run(args ...string) (string, string, error) {
+	saved := Clients
+	Clients = ClientFactory{Rest: e.rest.Factory(), Lnd: e.lnd.Factory()}
+	// Fiat rate is cached in home dir
+	savedHome := os.Getenv(homeEnv())
+	os.Setenv(homeEnv(), filepath.Dir(e.journal))
+	defer func() {
+		Clients = saved
+		os.Setenv(homeEnv(), savedHome)
+		amountUnit = UnitBTC
+		outputFormat = OutputJSON
+		fiatCurrency = ""
+		fiatRate = nil
+	}()
+
+	app := cli.NewApp()
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "output", Value: OutputJSON},
+		cli.StringFlag{Name: "unit", Value: UnitBTC},
+		cli.StringFlag{Name: "fiat"},
+		cli.StringFlag{Name: "journal"},
+		cli.StringFlag{Name: "config"},
+		cli.StringFlag{Name: "profile", Value: config.DefaultProfile},
+		cli.StringFlag{Name: "api-secret"},
+		cli.StringFlag{Name: "api-secret-file"},
+		cli.StringFlag{Name: "api-secret-agent"},
+	}
+	app.Before = func(c *cli.Context) error {
+		// Config commands manage profiles, including the ones which could not be applied
+		if c.Args().First() != Config.Name {
+			if err := config.Apply(c); err != nil {
+				return err
+			}
+		}
+		if err := SetUnit(c.String("unit")); err != nil {
+			return err
+		}
+		if err := SetFiat(c, c.String("fiat")); err != nil {
+			return err
+		}
+		return SetOutputFormat(c.String("output"))
+	}
+	app.OnUsageError = OnUsageError
+	app.Commands = WithUsageErrors([]cli.Command{Channel, Node, Payment, Api, Config})
+
+	var stdout, stderr string
+	err := capture(e.t, &stdout, &stderr, func() error {
+		return app.Run(append([]string{"daccs-cli", "--journal", e.journal, "--config", e.config}, args...))
+	})
+	return stdout, stderr, err
+}
+
+// capture stdout and stderr written by f
+func capture(t *testing.T, stdout, stderr *string, f func() error) error {
+	outR, outW, err := os.Pipe()
+	require.NoError(t, err)
+	errR, errW, err := os.Pipe()
+	require.NoError(t, err)
+	savedOut, savedErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	outDone := readAll(outR, stdout)
+	errDone := readAll(errR, stderr)
+
+	ferr := f()
+
+	os.Stdout, os.Stderr = savedOut, savedErr
+	outW.Close()
+	errW.Close()
+	<-outDone
+	<-errDone
+	return ferr
+}
+
+// readAll of r into s in background, the returned channel is closed on EOF
+func readAll(r io.ReadCloser, s *string) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer r.Close()
+		buf := bytes.Buffer{}
+		buf.ReadFrom(r)
+		*s = buf.String()
+	}()
+	return done
+}
+
+// homeEnv variable of user home dir
+func homeEnv() string {
+	if runtime.GOOS == "windows" {
+		return "USERPROFILE"
+	}
+	return "HOME"
+}
+
+// btc amount parsed from string
+func btc(value string) decimal.Decimal {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
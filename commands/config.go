@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/config"
+)
+
+// Config commands definition
+var Config = cli.Command{
+	Name:  "config",
+	Usage: "Configuration file profiles management commands",
+	Subcommands: []cli.Command{
+		{
+			Name:   "show",
+			Usage:  "Show settings of the profile selected by --profile",
+			Action: configShow,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "show-secrets", Usage: "Do not mask secret settings"},
+			},
+		},
+		{
+			Name:      "set",
+			Usage:     "Set setting of the profile selected by --profile, empty value removes the setting",
+			ArgsUsage: fmt.Sprintf("<%s> <value>", strings.Join(config.Keys, "|")),
+			Action:    configSet,
+		},
+		{
+			Name:   "profiles",
+			Usage:  "List configured profiles",
+			Action: configProfiles,
+		},
+	},
+}
+
+// configShow command handler
+func configShow(c *cli.Context) error {
+	f, err := config.Load(c.GlobalString("config"))
+	if err != nil {
+		return err
+	}
+	name := c.GlobalString("profile")
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return usageError("Unknown profile %s", name)
+	}
+	settings := config.Profile{}
+	for k, v := range profile {
+		if k == config.SecretKey && !c.Bool("show-secrets") {
+			v = "********"
+		}
+		settings[k] = v
+	}
+	return Response(struct {
+		Profile  string         `json:"profile"`
+		Settings config.Profile `json:"settings"`
+	}{name, settings})
+}
+
+// configSet command handler
+func configSet(c *cli.Context) error {
+	if c.NArg() != 2 {
+		cli.ShowCommandHelp(c, "set")
+		return nil
+	}
+	key, value := c.Args().Get(0), c.Args().Get(1)
+	// Raw secret can only be removed from profiles written by earlier versions
+	if key == config.SecretKey && value != "" {
+		return usageError("Setting %s is not stored in plaintext config, import it with secret import and set api-secret-file", key)
+	}
+	if !config.IsKey(key) && key != config.SecretKey {
+		return usageError("Unknown setting %s, expected one of %s", key, strings.Join(config.Keys, ", "))
+	}
+	f, err := config.Load(c.GlobalString("config"))
+	if err != nil {
+		return err
+	}
+	name := c.GlobalString("profile")
+	profile, ok := f.Profiles[name]
+	if !ok {
+		profile = config.Profile{}
+		f.Profiles[name] = profile
+	}
+	if value == "" {
+		delete(profile, key)
+	} else {
+		profile[key] = value
+	}
+	return f.Save(c.GlobalString("config"))
+}
+
+// configProfiles command handler
+func configProfiles(c *cli.Context) error {
+	f, err := config.Load(c.GlobalString("config"))
+	if err != nil {
+		return err
+	}
+	type profileInfo struct {
+		Name     string `json:"name"`
+		Selected bool   `json:"selected"`
+	}
+	res := []profileInfo{}
+	for _, name := range f.ProfileNames() {
+		res = append(res, profileInfo{Name: name, Selected: name == c.GlobalString("profile")})
+	}
+	return Response(res)
+}
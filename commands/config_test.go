@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSetShow(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+
+	_, _, err := e.run("config", "set", "api-key", "key-1")
+	require.NoError(t, err)
+	_, _, err = e.run("--profile", "test", "config", "set", "api-url", "http://127.0.0.1:8080/")
+	require.NoError(t, err)
+
+	stdout, _, err := e.run("config", "profiles")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"name":"default","selected":true},{"name":"test","selected":false}]`, stdout)
+
+	stdout, _, err = e.run("--profile", "test", "config", "show")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"profile":"test","settings":{"api-url":"http://127.0.0.1:8080/"}}`, stdout)
+
+	_, _, err = e.run("config", "set", "api-key", "")
+	require.NoError(t, err)
+	stdout, _, err = e.run("config", "show")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"profile":"default","settings":{}}`, stdout)
+
+	_, _, err = e.run("config", "set", "lnd-port", "10009")
+	require.Error(t, err)
+	assert.Equal(t, 2, ExitCode(err))
+	_, _, err = e.run("config", "set", "api-secret", "3077")
+	require.Error(t, err)
+	assert.Equal(t, 2, ExitCode(err))
+	_, _, err = e.run("--profile", "other", "config", "show")
+	require.Error(t, err)
+	assert.Equal(t, "Unknown profile other", err.Error())
+}
+
+func TestConfigRawSecret(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	// Profile written by earlier versions with raw secret
+	require.NoError(t, ioutil.WriteFile(e.config, []byte("[profiles.default]\napi-secret = \"3077\"\n"), 0600))
+
+	_, stderr, err := e.run("channel", "list")
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "Warning: setting api-secret of profile default is ignored")
+
+	stdout, _, err := e.run("config", "show")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"profile":"default","settings":{"api-secret":"********"}}`, stdout)
+	stdout, _, err = e.run("config", "show", "--show-secrets")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"profile":"default","settings":{"api-secret":"3077"}}`, stdout)
+
+	_, _, err = e.run("config", "set", "api-secret", "")
+	require.NoError(t, err)
+	stdout, _, err = e.run("config", "show")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"profile":"default","settings":{}}`, stdout)
+	_, stderr, err = e.run("channel", "list")
+	require.NoError(t, err)
+	assert.Equal(t, "", stderr)
+}
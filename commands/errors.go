@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/clients"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error codes of the error envelope
+const (
+	CodeError             = "error"
+	CodeUsage             = "usage_error"
+	CodeAuth              = "auth_error"
+	CodeAPIUnavailable    = "api_unavailable"
+	CodeLndUnavailable    = "lnd_unavailable"
+	CodeWalletLocked      = "wallet_locked"
+	CodeInsufficientFunds = "insufficient_funds"
+	CodePaymentFailed     = "payment_failed"
+)
+
+// exitCodes by error code
+var exitCodes = map[string]int{
+	CodeError:             1,
+	CodeUsage:             2,
+	CodeAuth:              3,
+	CodeAPIUnavailable:    4,
+	CodeLndUnavailable:    5,
+	CodeWalletLocked:      6,
+	CodeInsufficientFunds: 7,
+	CodePaymentFailed:     8,
+}
+
+// commandError annotates cause error with message, error code and details.
+// Empty code and nil details are taken from the cause
+type commandError struct {
+	code    string
+	message string
+	details interface{}
+	cause   error
+}
+
+// Error implements error interface
+func (e *commandError) Error() string {
+	return e.message
+}
+
+// Cause of the error
+func (e *commandError) Cause() error {
+	return e.cause
+}
+
+// newError with code and optional cause and details
+func newError(code string, cause error, details interface{}, format string, args ...interface{}) error {
+	return &commandError{code: code, message: fmt.Sprintf(format, args...), details: details, cause: cause}
+}
+
+// usageError of invalid command arguments
+func usageError(format string, args ...interface{}) error {
+	return newError(CodeUsage, nil, nil, format, args...)
+}
+
+// wrapError annotates err as "Error <err> on <action>"
+func wrapError(err error, action string) error {
+	return &commandError{message: fmt.Sprintf("Error %s on %s", err, action), cause: err}
+}
+
+// OnUsageError handler of invalid flags
+func OnUsageError(c *cli.Context, err error, isSubcommand bool) error {
+	return usageError("%s", err)
+}
+
+// WithUsageErrors sets OnUsageError handler for commands and all their subcommands
+func WithUsageErrors(cmds []cli.Command) []cli.Command {
+	for i := range cmds {
+		cmds[i].OnUsageError = OnUsageError
+		cmds[i].Subcommands = WithUsageErrors(cmds[i].Subcommands)
+	}
+	return cmds
+}
+
+// classify error into error code and details
+func classify(err error) (string, interface{}) {
+	code := ""
+	var details interface{}
+	for {
+		ce, ok := err.(*commandError)
+		if !ok {
+			break
+		}
+		if code == "" {
+			code = ce.code
+		}
+		if details == nil {
+			details = ce.details
+		}
+		if ce.cause == nil {
+			return code, details
+		}
+		err = ce.cause
+	}
+
+	rootCode := CodeError
+	switch e := err.(type) {
+	case *clients.APIError:
+		switch {
+		case e.StatusCode == 401 || e.StatusCode == 403:
+			rootCode = CodeAuth
+		case e.StatusCode == 0 || e.StatusCode == 429 || e.StatusCode >= 500:
+			rootCode = CodeAPIUnavailable
+		}
+		if details == nil {
+			details = e
+		}
+	case *clients.ConnectionError:
+		rootCode = CodeLndUnavailable
+	case *clients.UnlockError:
+		rootCode = CodeWalletLocked
+	default:
+		if s, ok := status.FromError(err); ok {
+			switch s.Code() {
+			case codes.Unavailable:
+				rootCode = CodeLndUnavailable
+			case codes.Unimplemented:
+				// lnd replies Unimplemented to Lightning service calls while wallet is locked
+				rootCode = CodeWalletLocked
+			}
+		}
+	}
+	if code == "" {
+		code = rootCode
+	}
+	return code, details
+}
+
+// ExitCode of the process failed with err
+func ExitCode(err error) int {
+	code, _ := classify(err)
+	return exitCodes[code]
+}
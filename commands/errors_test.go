@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassify(t *testing.T) {
+	apiErr := &clients.APIError{StatusCode: 400, Method: "POST", Path: "invoices", Message: "invalid account"}
+	details := struct{ Balance string }{"0.1"}
+	tests := []struct {
+		name    string
+		err     error
+		code    string
+		details interface{}
+		exit    int
+	}{
+		{"plain", errors.New("failed"), CodeError, nil, 1},
+		{"usage", usageError("Invalid account"), CodeUsage, nil, 2},
+		{"api unauthorized", &clients.APIError{StatusCode: 401}, CodeAuth, &clients.APIError{StatusCode: 401}, 3},
+		{"api forbidden", wrapError(&clients.APIError{StatusCode: 403}, "getting Limits"), CodeAuth, &clients.APIError{StatusCode: 403}, 3},
+		{"api network error", &clients.APIError{}, CodeAPIUnavailable, &clients.APIError{}, 4},
+		{"api rate limited", &clients.APIError{StatusCode: 429}, CodeAPIUnavailable, &clients.APIError{StatusCode: 429}, 4},
+		{"api server error", &clients.APIError{StatusCode: 502}, CodeAPIUnavailable, &clients.APIError{StatusCode: 502}, 4},
+		{"api client error", wrapError(apiErr, "getting invoices to pay"), CodeError, apiErr, 1},
+		{"lnd connection", &clients.ConnectionError{Host: "127.0.0.1:10009", Err: errors.New("refused")}, CodeLndUnavailable, nil, 5},
+		{"lnd unavailable", wrapError(status.Error(codes.Unavailable, "refused"), "getting channels list"), CodeLndUnavailable, nil, 5},
+		{"wallet locked", &clients.UnlockError{Err: errors.New("wallet is locked")}, CodeWalletLocked, nil, 6},
+		{"lnd unimplemented", status.Error(codes.Unimplemented, "unknown service lnrpc.Lightning"), CodeWalletLocked, nil, 6},
+		{"lnd other", status.Error(codes.Unknown, "invoice expired"), CodeError, nil, 1},
+		{"insufficient funds", newError(CodeInsufficientFunds, nil, details, "Insufficient funds"), CodeInsufficientFunds, details, 7},
+		{"payment failed", newError(CodePaymentFailed, nil, nil, "Payment failed"), CodePaymentFailed, nil, 8},
+		{
+			"outer code over cause",
+			newError(CodePaymentFailed, status.Error(codes.Unavailable, "refused"), details, "Payment failed"),
+			CodePaymentFailed, details, 8,
+		},
+		{
+			"code of cause",
+			newError("", status.Error(codes.Unavailable, "refused"), details, "Payment failed"),
+			CodeLndUnavailable, details, 5,
+		},
+		{
+			"wrapped details",
+			wrapError(newError(CodeInsufficientFunds, nil, details, "Insufficient funds"), "opening channel"),
+			CodeInsufficientFunds, details, 7,
+		},
+		{
+			"outer details over api error",
+			newError("", apiErr, details, "Withdrawal failed"),
+			CodeError, details, 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, details := classify(tt.err)
+			assert.Equal(t, tt.code, code)
+			assert.Equal(t, tt.details, details)
+			assert.Equal(t, tt.exit, ExitCode(tt.err))
+		})
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	var stdout, stderr string
+	err := capture(t, &stdout, &stderr, func() error {
+		ResponseError(newError(CodeInsufficientFunds, nil, struct {
+			MaxPaymentAmount decimal.Decimal `json:"max_payment_amount"`
+		}{btc("0.0098")}, "Amount is too big"))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", stdout)
+	assert.JSONEq(t, `{"code":"insufficient_funds","message":"Amount is too big","details":{"max_payment_amount":"0.0098"}}`, stderr)
+	assert.Equal(t, 7, ExitCode(newError(CodeInsufficientFunds, nil, nil, "Amount is too big")))
+}
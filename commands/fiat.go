@@ -0,0 +1,295 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/config"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// fiatRateTTL of cached mark price
+	fiatRateTTL = time.Minute
+	// fiatPrecision of fiat values
+	fiatPrecision = int32(2)
+	// fiatRateSource of mark prices
+	fiatRateSource = "xena mark price"
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// FiatRate of BTC amounts are valued at
+type FiatRate struct {
+	Currency  string          `json:"currency"`
+	Symbol    string          `json:"symbol"`
+	Price     decimal.Decimal `json:"price"`
+	Source    string          `json:"source"`
+	Timestamp time.Time       `json:"timestamp"`
+	Cached    bool            `json:"cached"`
+}
+
+// fiatRateCache file contents
+type fiatRateCache struct {
+	Rate      *FiatRate `json:"rate"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Fiat valuation of command responses, disabled if fiatCurrency is empty
+var (
+	fiatCurrency string
+	fiatRateFunc func() (*FiatRate, error)
+	fiatRate     *FiatRate
+)
+
+// SetFiat currency to value response amounts in, its rate is fetched on the first response
+func SetFiat(c *cli.Context, currency string) error {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	// The rate of previous currency must not be reused
+	fiatRate = nil
+	if currency == "" {
+		fiatCurrency = ""
+		return nil
+	}
+	if !currencyPattern.MatchString(currency) {
+		return usageError("Invalid fiat currency %s", currency)
+	}
+	fiatCurrency = currency
+	fiatRateFunc = func() (*FiatRate, error) {
+		return loadFiatRate(c, currency)
+	}
+	return nil
+}
+
+// loadFiatRate from cache unless it's older than TTL, from API otherwise
+func loadFiatRate(c *cli.Context, currency string) (*FiatRate, error) {
+	symbol := "XBT" + currency
+	path, pathErr := fiatRateCachePath(symbol)
+	if pathErr == nil {
+		if data, err := ioutil.ReadFile(path); err == nil {
+			cache := &fiatRateCache{}
+			if json.Unmarshal(data, cache) == nil && cache.Rate != nil && time.Since(cache.FetchedAt) < fiatRateTTL {
+				cache.Rate.Cached = true
+				return cache.Rate, nil
+			}
+		}
+	}
+
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return nil, err
+	}
+	price, err := restcli.MarkPrice(symbol)
+	if err != nil {
+		return nil, wrapError(err, fmt.Sprintf("getting %s mark price", symbol))
+	}
+	rate := &FiatRate{
+		Currency:  currency,
+		Symbol:    symbol,
+		Price:     price.Price,
+		Source:    fiatRateSource,
+		Timestamp: price.Timestamp,
+	}
+	// Failure to cache the rate only costs another request next time
+	if pathErr == nil {
+		if data, err := json.Marshal(&fiatRateCache{Rate: rate, FetchedAt: time.Now().UTC()}); err == nil {
+			if os.MkdirAll(filepath.Dir(path), 0700) == nil {
+				ioutil.WriteFile(path, data, 0600)
+			}
+		}
+	}
+	return rate, nil
+}
+
+// fiatRateCachePath of symbol
+func fiatRateCachePath(symbol string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cache", "mark-price-"+symbol+".json"), nil
+}
+
+// pendingFiat value of amount added before the rate is known
+type pendingFiat decimal.Decimal
+
+// inFiat adds fiat values after response amounts and the rate they are valued at.
+// Response is returned as ordered value which marshals to JSON keeping fields order.
+// Responses without amounts are returned as is, so the rate is not fetched for them
+func inFiat(res interface{}) (interface{}, error) {
+	if fiatCurrency == "" || res == nil {
+		return res, nil
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeOrdered(json.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	value = addFiat(reflect.TypeOf(res), value, &found)
+	if !found {
+		return res, nil
+	}
+	if fiatRate == nil {
+		rate, err := fiatRateFunc()
+		if err != nil {
+			return nil, err
+		}
+		fiatRate = rate
+	}
+	value = resolveFiat(value)
+
+	// Every object item gets the rate
+	rate := yaml.MapSlice{
+		{Key: "currency", Value: fiatRate.Currency},
+		{Key: "symbol", Value: fiatRate.Symbol},
+		{Key: "price", Value: fiatRate.Price.String()},
+		{Key: "source", Value: fiatRate.Source},
+		{Key: "timestamp", Value: fiatRate.Timestamp.Format(time.RFC3339)},
+		{Key: "cached", Value: fiatRate.Cached},
+	}
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		value = append(v, yaml.MapItem{Key: "fiat_rate", Value: rate})
+	case []interface{}:
+		for i, item := range v {
+			if obj, ok := item.(yaml.MapSlice); ok {
+				v[i] = append(obj, yaml.MapItem{Key: "fiat_rate", Value: rate})
+			}
+		}
+	}
+	return jsonValue(value), nil
+}
+
+// addFiat pending values after BTC amount fields of ordered value of type t, found is set if any is added
+func addFiat(t reflect.Type, value interface{}, found *bool) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return v
+		}
+		for i := range v {
+			v[i] = addFiat(t.Elem(), v[i], found)
+		}
+		return v
+	case yaml.MapSlice:
+		if t.Kind() != reflect.Struct {
+			return v
+		}
+		fields := jsonFields(t)
+		btc := true
+		for _, item := range v {
+			if f, ok := fields[fmt.Sprint(item.Key)]; ok && f.Tag.Get("unit") == unitTagCurrency {
+				btc = isBTC(fmt.Sprint(item.Value))
+			}
+		}
+		res := make(yaml.MapSlice, 0, len(v))
+		for _, item := range v {
+			f, ok := fields[fmt.Sprint(item.Key)]
+			if !ok {
+				res = append(res, item)
+				continue
+			}
+			if f.Type != decimalType {
+				res = append(res, yaml.MapItem{Key: item.Key, Value: addFiat(f.Type, item.Value, found)})
+				continue
+			}
+			res = append(res, item)
+			if !btc || !isBTCAmount(f) {
+				continue
+			}
+			s, ok := item.Value.(string)
+			if !ok {
+				continue
+			}
+			amount, err := decimal.NewFromString(s)
+			if err != nil {
+				continue
+			}
+			res = append(res, yaml.MapItem{Key: fiatKey(fmt.Sprint(item.Key)), Value: pendingFiat(amount)})
+			*found = true
+		}
+		return res
+	}
+	return value
+}
+
+// resolveFiat pending values of ordered value at fiat rate
+func resolveFiat(value interface{}) interface{} {
+	switch v := value.(type) {
+	case pendingFiat:
+		return fiatValue(decimal.Decimal(v)).String()
+	case []interface{}:
+		for i := range v {
+			v[i] = resolveFiat(v[i])
+		}
+	case yaml.MapSlice:
+		for i := range v {
+			v[i].Value = resolveFiat(v[i].Value)
+		}
+	}
+	return value
+}
+
+// fiatKey of fiat value of amount field
+func fiatKey(key string) string {
+	return key + "_" + strings.ToLower(fiatCurrency)
+}
+
+// fiatValue of amount given in amount unit
+func fiatValue(amount decimal.Decimal) decimal.Decimal {
+	btc := amount.Mul(decimal.New(1, -unitShifts[amountUnit]))
+	return btc.Mul(fiatRate.Price).Round(fiatPrecision)
+}
+
+// jsonFields of struct type by JSON name, including fields of embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	res := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for k, v := range jsonFields(ft) {
+				if _, ok := res[k]; !ok {
+					res[k] = v
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		res[name] = f
+	}
+	return res
+}
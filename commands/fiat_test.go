@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+func TestInFiatCurrency(t *testing.T) {
+	defer func() {
+		fiatCurrency = ""
+		fiatRate = nil
+	}()
+	fiatCurrency = "USD"
+	fiatRate = &FiatRate{Currency: "USD", Symbol: "XBTUSD", Price: btc("10000"), Source: fiatRateSource}
+
+	res, err := inFiat([]*clients.AccountBalance{
+		{AccountID: 1, Currency: "BTC", Total: btc("0.01"), Available: btc("0.005")},
+		{AccountID: 2, Currency: "USD", Total: btc("150.5"), Available: btc("100")},
+	})
+	require.NoError(t, err)
+	data, err := json.Marshal(res)
+	require.NoError(t, err)
+	var v []map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &v))
+	require.Len(t, v, 2)
+	assert.Equal(t, "100", v[0]["total_usd"])
+	assert.Equal(t, "50", v[0]["available_usd"])
+	_, ok := v[1]["total_usd"]
+	assert.False(t, ok)
+	_, ok = v[1]["available_usd"]
+	assert.False(t, ok)
+
+	// Prices are not amounts
+	res, err = inFiat(&clients.MarkPrice{Symbol: "XBTUSD", Price: btc("9000")})
+	require.NoError(t, err)
+	data, err = json.Marshal(res)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "price_usd")
+}
+
+func TestFiatValuation(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()[:1]
+
+	for _, unit := range []string{UnitBTC, UnitSat} {
+		stdout, _, err := e.run("--unit", unit, "--fiat", "usd", "channel", "list")
+		require.NoError(t, err)
+		var res []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+		require.Len(t, res, 1)
+		assert.Equal(t, "100", res[0]["local_balance_usd"], unit)
+		assert.Equal(t, "200", res[0]["capacity_usd"], unit)
+		rate, ok := res[0]["fiat_rate"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, "XBTUSD", rate["symbol"])
+		assert.Equal(t, "10000", rate["price"])
+	}
+
+	_, _, err := e.run("--fiat", "usdt", "channel", "list")
+	require.Error(t, err)
+	assert.Equal(t, "Invalid fiat currency USDT", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+
+	_, _, err = e.run("--fiat", "eur", "channel", "list")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "getting XBTEUR mark price")
+}
+
+func TestFiatRateCache(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()[:1]
+	rate := func() map[string]interface{} {
+		stdout, _, err := e.run("--fiat", "usd", "channel", "list")
+		require.NoError(t, err)
+		var res []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+		require.Len(t, res, 1)
+		return res[0]["fiat_rate"].(map[string]interface{})
+	}
+
+	r := rate()
+	assert.Equal(t, "10000", r["price"])
+	assert.Equal(t, false, r["cached"])
+
+	// The cached rate is used until it expires
+	e.rest.MarkPrices["XBTUSD"] = btc("12000")
+	r = rate()
+	assert.Equal(t, "10000", r["price"])
+	assert.Equal(t, true, r["cached"])
+
+	path := filepath.Join(filepath.Dir(e.journal), ".config", "daccs-cli", "cache", "mark-price-XBTUSD.json")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	cache := &fiatRateCache{}
+	require.NoError(t, json.Unmarshal(data, cache))
+	cache.FetchedAt = time.Now().Add(-fiatRateTTL - time.Second)
+	data, err = json.Marshal(cache)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	r = rate()
+	assert.Equal(t, "12000", r["price"])
+	assert.Equal(t, false, r["cached"])
+
+	// Rate of other currency is not taken from the one of previous response
+	e.rest.MarkPrices["XBTEUR"] = btc("9000")
+	stdout, _, err := e.run("--fiat", "eur", "channel", "list")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, `"local_balance_eur": "90"`)
+
+	defer SetFiat(nil, "")
+	fiatRate = &FiatRate{Currency: "USD", Price: btc("10000")}
+	require.NoError(t, SetFiat(nil, "eur"))
+	assert.Nil(t, fiatRate)
+}
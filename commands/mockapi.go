@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/clients"
+	"github.com/xenaex/daccs-cli/mockapi"
+)
+
+// MockAPI command definition
+var MockAPI = cli.Command{
+	Name:  "mock-api",
+	Usage: "Serve local stand-in for Xena dAccs API to run offline tests and demos against",
+	Description: "Issued invoices and withdrawals stay unpaid until settled by authenticated\n" +
+		"   POST /invoices/<payment hash>/settle with {\"amount\": \"<BTC amount>\"} body",
+	Action: mockAPI,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "listen", Value: "127.0.0.1:8080", Usage: "Address to listen on"},
+		cli.StringFlag{Name: "api-pubkey", Usage: "Hex encoded DER public key to verify requests with (derived from api-secret if omitted)"},
+		cli.StringFlag{Name: "node-key", Usage: "Hex encoded secp256k1 private key to sign invoices with (random if omitted)"},
+		cli.StringSliceFlag{Name: "node", Usage: "Node to advertise as id=pubkey@host (node-key based one if omitted)"},
+		cli.StringSliceFlag{Name: "account", Usage: "BTC account of the user as id[:kind], kind is margin if omitted"},
+		cli.StringFlag{Name: "network", Value: "regtest", Usage: "Network to issue invoices for: mainnet, testnet, regtest or simnet"},
+		cli.StringFlag{Name: "min-channel-capacity", Value: "0.001"},
+		cli.StringFlag{Name: "min-payment-amount", Value: "0.00001"},
+		cli.StringFlag{Name: "channel-reserve-multiplier", Value: "1"},
+		cli.UintFlag{Name: "min-remote-csv-delay", Value: 144},
+		cli.UintFlag{Name: "max-remote-csv-delay", Value: 2016},
+		cli.StringFlag{Name: "max-push-amount", Value: "0", Usage: "Max amount pushed on channel open, unlimited if 0"},
+		cli.StringFlag{Name: "max-min-htlc", Value: "0", Usage: "Max min HTLC amount of channel, unlimited if 0"},
+		cli.BoolFlag{Name: "public-channels", Usage: "Allow public channels, --public-channels=false disallows them (default unconstrained)"},
+		cli.StringSliceFlag{Name: "mark-price", Usage: "Mark price as symbol=price, e.g. XBTUSD=10000 (default)"},
+	},
+}
+
+// mockAPI command handler
+func mockAPI(c *cli.Context) error {
+	// API public key to verify signatures with
+	var apiPubKey *ecdsa.PublicKey
+	if s := c.String("api-pubkey"); s != "" {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			return usageError("Error %s on decoding api-pubkey", err)
+		}
+		key, err := x509.ParsePKIXPublicKey(data)
+		if err != nil {
+			return usageError("Error %s on parsing api-pubkey", err)
+		}
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return usageError("api-pubkey is not an ECDSA public key")
+		}
+		apiPubKey = ecKey
+	} else {
+		key, err := clients.LoadAPISecret(c)
+		if err != nil {
+			return usageError("Either api-pubkey or api secret required: %s", err)
+		}
+		apiPubKey = &key.PublicKey
+	}
+
+	// Node key to sign invoices with
+	var nodeKey *btcec.PrivateKey
+	if s := c.String("node-key"); s != "" {
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			return usageError("Error %s on decoding node-key", err)
+		}
+		nodeKey, _ = btcec.PrivKeyFromBytes(btcec.S256(), data)
+	} else {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			return wrapError(err, "generating node key")
+		}
+		nodeKey = key
+	}
+	nodePubKey := hex.EncodeToString(nodeKey.PubKey().SerializeCompressed())
+
+	// Network
+	var net *chaincfg.Params
+	switch c.String("network") {
+	case "mainnet":
+		net = &chaincfg.MainNetParams
+	case "testnet":
+		net = &chaincfg.TestNet3Params
+	case "regtest":
+		net = &chaincfg.RegressionNetParams
+	case "simnet":
+		net = &chaincfg.SimNetParams
+	default:
+		return usageError("Unknown network %s", c.String("network"))
+	}
+
+	// Nodes
+	nodes := []*clients.Node{}
+	for _, n := range c.StringSlice("node") {
+		parts := strings.SplitN(n, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || !strings.Contains(parts[1], "@") {
+			return usageError("Invalid node %s, expected id=pubkey@host", n)
+		}
+		nodes = append(nodes, &clients.Node{ID: parts[0], Address: parts[1]})
+	}
+	if len(nodes) == 0 {
+		nodes = append(nodes, &clients.Node{ID: "mock-1", Address: nodePubKey + "@127.0.0.1:9735"})
+	}
+
+	// Accounts
+	accounts := []*clients.Account{}
+	for _, a := range c.StringSlice("account") {
+		parts := strings.SplitN(a, ":", 2)
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			return usageError("Invalid account %s, expected id[:kind]", a)
+		}
+		kind := "margin"
+		if len(parts) == 2 && parts[1] != "" {
+			kind = parts[1]
+		}
+		accounts = append(accounts, &clients.Account{ID: id, Kind: kind, Currency: "BTC"})
+	}
+
+	// Limits
+	limits := clients.Limits{
+		ChannelOpen: clients.ChannelOpenLimits{
+			MinRemoteCsvDelay: uint32(c.Uint("min-remote-csv-delay")),
+			MaxRemoteCsvDelay: uint32(c.Uint("max-remote-csv-delay")),
+		},
+	}
+	if c.IsSet("public-channels") {
+		publicAllowed := c.Bool("public-channels")
+		limits.ChannelOpen.PublicAllowed = &publicAllowed
+	}
+	for name, v := range map[string]*decimal.Decimal{
+		"min-channel-capacity":       &limits.MinChannelCapacity,
+		"min-payment-amount":         &limits.MinPaymentAmount,
+		"channel-reserve-multiplier": &limits.ChannelReserveMultiplier,
+		"max-push-amount":            &limits.ChannelOpen.MaxPushAmount,
+		"max-min-htlc":               &limits.ChannelOpen.MaxMinHtlc,
+	} {
+		d, err := decimal.NewFromString(c.String(name))
+		if err != nil {
+			return usageError("Invalid %s value", name)
+		}
+		*v = d
+	}
+
+	// Mark prices
+	markPrices := map[string]decimal.Decimal{}
+	for _, p := range c.StringSlice("mark-price") {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return usageError("Invalid mark price %s, expected symbol=price", p)
+		}
+		price, err := decimal.NewFromString(parts[1])
+		if err != nil {
+			return usageError("Invalid mark price %s, expected symbol=price", p)
+		}
+		markPrices[strings.ToUpper(parts[0])] = price
+	}
+	if len(markPrices) == 0 {
+		markPrices["XBTUSD"] = decimal.New(10000, 0)
+	}
+
+	srv, err := mockapi.NewServer(mockapi.Config{
+		APIKey:     c.GlobalString("api-key"),
+		APIPubKey:  apiPubKey,
+		NodeKey:    nodeKey,
+		Net:        net,
+		Nodes:      nodes,
+		Limits:     limits,
+		Accounts:   accounts,
+		MarkPrices: markPrices,
+	})
+	if err != nil {
+		return err
+	}
+	err = Response(struct {
+		APIURL     string          `json:"api_url"`
+		NodePubKey string          `json:"node_pubkey"`
+		Nodes      []*clients.Node `json:"nodes"`
+	}{fmt.Sprintf("http://%s/", c.String("listen")), nodePubKey, nodes})
+	if err != nil {
+		return err
+	}
+	return http.ListenAndServe(c.String("listen"), srv)
+}
@@ -5,7 +5,6 @@ import (
 
 	"github.com/shopspring/decimal"
 	"github.com/urfave/cli"
-	"github.com/xenaex/daccs-cli/clients"
 )
 
 // Node commands definition
@@ -41,6 +40,9 @@ var Node = cli.Command{
 			Name:   "balance",
 			Usage:  "Get local LND node balance",
 			Action: nodeBalance,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "detailed", Usage: "Break balance down into on-chain, channel, pending and limbo amounts"},
+			},
 		},
 		{
 			Name:   "deposit",
@@ -67,58 +69,56 @@ func nodeUnlock(c *cli.Context) error {
 	}
 	pwd := c.String("password")
 	if pwd == "" {
-		return fmt.Errorf("Unlock password required")
+		return usageError("Unlock password required")
 	}
-	lncli, err := clients.NewLndClient(c, false)
+	lncli, err := Clients.Lnd(c, false)
 	if err != nil {
 		return err
 	}
 	err = lncli.Unlock(pwd)
 	if err != nil {
-		return fmt.Errorf("Error %s on unlocking LND node", err)
+		return newError(CodeWalletLocked, err, nil, "Error %s on unlocking LND node", err)
 	}
 	return nil
 }
 
 func nodeStatus(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
 	info, err := lncli.Status()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting LND node status", err)
+		return wrapError(err, "getting LND node status")
 	}
-	ResponseJSON(info)
-	return nil
+	return Response(info)
 }
 
 func nodePeers(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
 	peers, err := lncli.Peers()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting LND node peers", err)
+		return wrapError(err, "getting LND node peers")
 	}
-	ResponseJSON(peers)
-	return nil
+	return Response(peers)
 }
 
 func nodeDisconnect(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
 	peers, err := lncli.Peers()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting LND node peers", err)
+		return wrapError(err, "getting LND node peers")
 	}
 	for _, p := range peers {
 		err = lncli.Disconnect(p)
 		if err != nil {
-			return fmt.Errorf("Error %s on disconnecting from %s", err, p)
+			return wrapError(err, fmt.Sprintf("disconnecting from %s", p))
 		}
 	}
 
@@ -126,40 +126,45 @@ func nodeDisconnect(c *cli.Context) error {
 }
 
 func nodeBalance(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
+	if c.Bool("detailed") {
+		res, err := lncli.DetailedBalance()
+		if err != nil {
+			return wrapError(err, "getting LND node balance")
+		}
+		return Response(res)
+	}
 	bal, err := lncli.Balance()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting LND node balance", err)
+		return wrapError(err, "getting LND node balance")
 	}
 	resp := struct {
-		Balance decimal.Decimal `json:"balance"`
+		Balance decimal.Decimal `json:"balance" unit:"btc"`
 	}{bal}
-	ResponseJSON(resp)
-	return nil
+	return Response(resp)
 }
 
 func nodeDeposit(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
 	addr, err := lncli.FundingAddress()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting LND node balance", err)
+		return wrapError(err, "getting LND node balance")
 	}
 	resp := struct {
 		Address string `json:"address"`
 	}{addr}
-	ResponseJSON(resp)
-	return nil
+	return Response(resp)
 }
 
 // transactionList command handler
 func transactionList(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
@@ -167,6 +172,5 @@ func transactionList(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	ResponseJSON(res)
-	return nil
+	return Response(res)
 }
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+func TestNodeBalance(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.WalletBalance = btc("0.5")
+
+	stdout, _, err := e.run("--unit", "sat", "node", "balance")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"balance":"50000000"}`, stdout)
+}
+
+func TestNodeBalanceDetailed(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.WalletBalance = btc("0.5")
+	e.lnd.UnconfirmedBalance = btc("0.1")
+	e.lnd.ChannelList = append(testChannels()[:2],
+		&clients.ChannelStatus{ID: 4, ChannelPoint: "tx4:0", Status: "pending_open", LocalBalance: btc("0.03")},
+		&clients.ChannelStatus{ID: 5, ChannelPoint: "tx5:0", Status: "pending_closing", LocalBalance: btc("0.04")},
+		&clients.ChannelStatus{ID: 6, ChannelPoint: "tx6:0", Status: "force_closing", LocalBalance: btc("0.05")},
+	)
+
+	stdout, _, err := e.run("node", "balance", "--detailed")
+	require.NoError(t, err)
+	res := &clients.BalanceDetails{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), res))
+	assert.Equal(t, "0.5", res.OnChainConfirmed.String())
+	assert.Equal(t, "0.1", res.OnChainUnconfirmed.String())
+	assert.Equal(t, "0.02", res.ChannelLocal.String())
+	assert.Equal(t, "0.01", res.ChannelRemote.String())
+	assert.Equal(t, "0.03", res.PendingOpen.String())
+	assert.Equal(t, "0.04", res.PendingClose.String())
+	assert.Equal(t, "0.05", res.Limbo.String())
+	// Remote balance is not the local node funds
+	assert.Equal(t, "0.74", res.Total.String())
+
+	stdout, _, err = e.run("--unit", "mbtc", "node", "balance", "--detailed")
+	require.NoError(t, err)
+	res = &clients.BalanceDetails{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), res))
+	assert.Equal(t, "740", res.Total.String())
+	assert.Equal(t, "10", res.ChannelRemote.String())
+
+	e.lnd.Errors["DetailedBalance"] = errors.New("rpc error")
+	_, _, err = e.run("node", "balance", "--detailed")
+	require.Error(t, err)
+	assert.Equal(t, "Error rpc error on getting LND node balance", err.Error())
+}
@@ -0,0 +1,359 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xenaex/daccs-cli/clients"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Output formats
+const (
+	OutputJSON  = "json"
+	OutputJSONL = "jsonl"
+	OutputTable = "table"
+	OutputCSV   = "csv"
+	OutputYAML  = "yaml"
+)
+
+// OutputFormats supported
+var OutputFormats = []string{OutputJSON, OutputJSONL, OutputTable, OutputCSV, OutputYAML}
+
+// outputFormat of command responses
+var outputFormat = OutputJSON
+
+// columns of table and CSV output by response item type, given as JSON field names.
+// Items of other types get columns for all of their fields
+var columns = map[reflect.Type][]string{
+	reflect.TypeOf(clients.ChannelStatus{}): {"id", "short_channel_id", "node", "channel_point", "status", "capacity", "local_balance", "remote_balance", "closing_txid"},
+	reflect.TypeOf(clients.ClosedChannel{}): {"id", "node", "channel_point", "capacity", "settled_balance", "time_locked_balance", "closing_txid", "close_height", "close_type"},
+	reflect.TypeOf(clients.Payment{}):       {"payment_hash", "node", "timestamp", "amount", "fee", "status"},
+	reflect.TypeOf(clients.Transaction{}):   {"txid", "amount", "num_confirmations", "block_height", "timestamp", "total_fees", "dest_addresses"},
+	reflect.TypeOf(RemoteNode{}):            {"id", "pubKey"},
+}
+
+// SetOutputFormat of command responses
+func SetOutputFormat(format string) error {
+	for _, f := range OutputFormats {
+		if f == format {
+			outputFormat = format
+			return nil
+		}
+	}
+	return usageError("Unknown output format %s, expected one of %s", format, strings.Join(OutputFormats, ", "))
+}
+
+// Response formatter according to output format, amount unit and fiat currency.
+// Failure to convert or write the response is returned to exit with error code
+func Response(res interface{}) error {
+	res, err := inUnit(res)
+	if err != nil {
+		return wrapError(err, "converting response amounts")
+	}
+	t := itemType(res)
+	res, err = inFiat(res)
+	if err != nil {
+		return err
+	}
+	if outputFormat == OutputJSON {
+		return ResponseJSON(res)
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return wrapError(err, "encoding response")
+	}
+	value, err := decodeOrdered(json.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return wrapError(err, "encoding response")
+	}
+	switch outputFormat {
+	case OutputJSONL:
+		err = writeJSONL(os.Stdout, value)
+	case OutputTable, OutputCSV:
+		header, rows := tabulate(t, value)
+		if outputFormat == OutputTable {
+			err = writeTable(os.Stdout, header, rows)
+		} else {
+			err = writeCSV(os.Stdout, header, rows)
+		}
+	case OutputYAML:
+		data, err = yaml.Marshal(value)
+		if err == nil {
+			_, err = os.Stdout.Write(data)
+		}
+	}
+	if err != nil {
+		return wrapError(err, "writing response")
+	}
+	return nil
+}
+
+// Event of command progress written to stderr as JSON line regardless of output format,
+// so stdout is left to the response. Fields of data object follow the event name
+func Event(name string, data interface{}) {
+	data, err := inUnit(data)
+	if err != nil {
+		ResponseError(err)
+		return
+	}
+	obj := yaml.MapSlice{{Key: "event", Value: name}}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			ResponseError(err)
+			return
+		}
+		value, err := decodeOrdered(json.NewDecoder(bytes.NewReader(raw)))
+		if err != nil {
+			ResponseError(err)
+			return
+		}
+		fields, _ := value.(yaml.MapSlice)
+		obj = append(obj, fields...)
+	}
+	if err = writeJSONL(os.Stderr, obj); err != nil {
+		ResponseError(err)
+	}
+}
+
+// itemType of response, element type for slices
+func itemType(res interface{}) reflect.Type {
+	t := reflect.TypeOf(res)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	return t
+}
+
+// decodeOrdered JSON value keeping order of object fields as yaml.MapSlice
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := yaml.MapSlice{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, yaml.MapItem{Key: key, Value: value})
+			}
+			_, err = dec.Token()
+			return obj, err
+		case '[':
+			arr := []interface{}{}
+			for dec.More() {
+				value, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, value)
+			}
+			_, err = dec.Token()
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected %s", t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		if f, err := t.Float64(); err == nil {
+			return f, nil
+		}
+		return t.String(), nil
+	default:
+		return t, nil
+	}
+}
+
+// tabulate value into header and rows, value is either an object or an array of objects
+func tabulate(t reflect.Type, value interface{}) ([]string, [][]string) {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+	header, ok := columns[t]
+	if ok && fiatCurrency != "" {
+		header = withFiatColumns(header, items)
+	}
+	if !ok {
+		header = []string{}
+		seen := map[string]bool{}
+		for _, item := range items {
+			obj, _ := item.(yaml.MapSlice)
+			for _, f := range obj {
+				key := fmt.Sprint(f.Key)
+				if !seen[key] {
+					seen[key] = true
+					header = append(header, key)
+				}
+			}
+		}
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		fields := map[string]interface{}{}
+		if obj, ok := item.(yaml.MapSlice); ok {
+			for _, f := range obj {
+				fields[fmt.Sprint(f.Key)] = f.Value
+			}
+		}
+		row := make([]string, 0, len(header))
+		for _, h := range header {
+			row = append(row, formatCell(fields[h]))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// withFiatColumns inserts fiat value columns after amount ones and appends the rate column
+func withFiatColumns(header []string, items []interface{}) []string {
+	keys := map[string]bool{}
+	for _, item := range items {
+		obj, _ := item.(yaml.MapSlice)
+		for _, f := range obj {
+			keys[fmt.Sprint(f.Key)] = true
+		}
+	}
+	res := make([]string, 0, 2*len(header)+1)
+	for _, h := range header {
+		res = append(res, h)
+		if keys[fiatKey(h)] {
+			res = append(res, fiatKey(h))
+		}
+	}
+	return append(res, "fiat_rate")
+}
+
+// formatCell of table or CSV output
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, i := range v {
+			parts = append(parts, formatCell(i))
+		}
+		return strings.Join(parts, ",")
+	case yaml.MapSlice:
+		parts := make([]string, 0, len(v))
+		for _, f := range v {
+			parts = append(parts, fmt.Sprintf("%v=%s", f.Key, formatCell(f.Value)))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeJSONL writes array items or a single value one per line
+func writeJSONL(w io.Writer, value interface{}) error {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+	for _, item := range items {
+		data, err := json.Marshal(jsonValue(item))
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonValue converts ordered value to the one marshaled by encoding/json keeping fields order
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		return orderedObject(v)
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, i := range v {
+			res = append(res, jsonValue(i))
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// orderedObject marshals to JSON object keeping fields order
+type orderedObject yaml.MapSlice
+
+// MarshalJSON implements json.Marshaler
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(fmt.Sprint(f.Key))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(jsonValue(f.Value))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeTable with aligned columns
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	upper := make([]string, 0, len(header))
+	for _, h := range header {
+		upper = append(upper, strings.ToUpper(h))
+	}
+	fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeCSV with header row
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
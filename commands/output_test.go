@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+func TestTabulate(t *testing.T) {
+	type item struct {
+		Name  string   `json:"name"`
+		Tags  []string `json:"tags,omitempty"`
+		Count int      `json:"count"`
+	}
+	tests := []struct {
+		name   string
+		res    interface{}
+		header []string
+		rows   [][]string
+	}{
+		{
+			name: "columns of known type",
+			res: []*clients.ChannelStatus{
+				{ID: 1, ShortChannelID: "0x0x1", Node: "03bb", ChannelPoint: "tx1:0", Status: "active",
+					Capacity: btc("0.02"), LocalBalance: btc("0.015"), RemoteBalance: btc("0.005")},
+				{Node: "03cc", ChannelPoint: "tx2:0", Status: "waiting_close", Capacity: btc("0.01"), ClosingTxid: "tx3"},
+			},
+			header: []string{"id", "short_channel_id", "node", "channel_point", "status", "capacity", "local_balance", "remote_balance", "closing_txid"},
+			rows: [][]string{
+				{"1", "0x0x1", "03bb", "tx1:0", "active", "0.02", "0.015", "0.005", ""},
+				{"", "", "03cc", "tx2:0", "waiting_close", "0.01", "0", "0", "tx3"},
+			},
+		},
+		{
+			name:   "fields of single object",
+			res:    item{Name: "a", Tags: []string{"x", "y"}, Count: 2},
+			header: []string{"name", "tags", "count"},
+			rows:   [][]string{{"a", "x,y", "2"}},
+		},
+		{
+			name:   "union of fields",
+			res:    []item{{Name: "a", Count: 1}, {Name: "b", Tags: []string{"x"}, Count: 2}},
+			header: []string{"name", "count", "tags"},
+			rows:   [][]string{{"a", "1", ""}, {"b", "2", "x"}},
+		},
+		{
+			name:   "empty list",
+			res:    []item{},
+			header: []string{},
+			rows:   [][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.res)
+			require.NoError(t, err)
+			value, err := decodeOrdered(json.NewDecoder(bytes.NewReader(data)))
+			require.NoError(t, err)
+			header, rows := tabulate(itemType(tt.res), value)
+			assert.Equal(t, tt.header, header)
+			assert.Equal(t, tt.rows, rows)
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		rows   [][]string
+		csv    string
+	}{
+		{"header only", []string{"id", "node"}, nil, "id,node\n"},
+		{"rows", []string{"id", "node"}, [][]string{{"1", "03bb"}, {"2", "03cc"}}, "id,node\n1,03bb\n2,03cc\n"},
+		{"quoted", []string{"tags"}, [][]string{{"x,y"}, {`say "hi"`}}, "tags\n\"x,y\"\n\"say \"\"hi\"\"\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			require.NoError(t, writeCSV(&buf, tt.header, tt.rows))
+			assert.Equal(t, tt.csv, buf.String())
+		})
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, writeTable(&buf, []string{"id", "channel_point"}, [][]string{{"1", "tx1:0"}, {"12", "tx2:0"}}))
+	assert.Equal(t, "ID  CHANNEL_POINT\n1   tx1:0\n12  tx2:0\n", buf.String())
+}
+
+func TestItemType(t *testing.T) {
+	assert.Equal(t, reflect.TypeOf(clients.ChannelStatus{}), itemType([]*clients.ChannelStatus{}))
+	assert.Equal(t, reflect.TypeOf(clients.ChannelStatus{}), itemType(&clients.ChannelStatus{}))
+	assert.Nil(t, itemType(nil))
+}
+
+func TestResponseErrors(t *testing.T) {
+	// Value which can't be encoded
+	err := Response(map[string]interface{}{"value": make(chan int)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "on encoding response")
+	assert.Equal(t, 1, ExitCode(err))
+
+	// Stdout closed by the reader
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	r.Close()
+	w.Close()
+	saved := os.Stdout
+	os.Stdout = w
+	for _, format := range OutputFormats {
+		require.NoError(t, SetOutputFormat(format))
+		err = Response([]clients.Payment{{PaymentHash: "h1"}})
+		assert.Error(t, err, format)
+		assert.Equal(t, 1, ExitCode(err), format)
+	}
+	os.Stdout = saved
+	outputFormat = OutputJSON
+}
@@ -2,13 +2,17 @@ package commands
 
 import (
 	"fmt"
-	"os"
+	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/urfave/cli"
 	"github.com/xenaex/daccs-cli/clients"
+	"github.com/xenaex/daccs-cli/journal"
 )
 
 // Payment commands definition
@@ -35,14 +39,79 @@ var Payment = cli.Command{
 				cli.StringFlag{Name: "amount"},
 				cli.Uint64Flag{Name: "channel-id"},
 				cli.StringFlag{Name: "channel-point"},
+				cli.BoolFlag{Name: "auto", Usage: "Split the amount across all active channels with Xena lnd nodes instead of paying via the specified one"},
+				cli.StringFlag{Name: "external-id", Usage: "Unique id of the deposit, re-running with the same id resumes or reports the earlier attempt"},
+				cli.DurationFlag{Name: "timeout", Value: defaultPaymentTimeout, Usage: "Time to wait for the payment to reach a final state, it is reported in flight after that"},
+			},
+		},
+		{
+			Name:   "status",
+			Usage:  "Reconcile payment state of local lnd node with invoice state on Xena side",
+			Action: paymentStatus,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "hash", Usage: "Payment hash"},
+				cli.StringFlag{Name: "external-id", Usage: "External id the payment was sent with, parts of payment sent with --auto are reported together"},
+				cli.Int64Flag{Name: "account", Usage: "Account the payment was sent to, required if external id was used for several accounts"},
+			},
+		},
+		{
+			Name:   "withdraw",
+			Usage:  "Withdraw specified amount from account to the local node via specified channel",
+			Action: paymentWithdraw,
+			Flags: []cli.Flag{
+				cli.Int64Flag{Name: "account"},
+				cli.StringFlag{Name: "amount"},
+				cli.Uint64Flag{Name: "channel-id"},
+				cli.StringFlag{Name: "channel-point"},
+				cli.DurationFlag{Name: "timeout", Value: defaultPaymentTimeout, Usage: "Time to wait for the invoice to be paid, it is reported open after that"},
 			},
 		},
 	},
 }
 
+const defaultPaymentTimeout = 60 * time.Second
+
+// PaymentSendResult of payment send command
+type PaymentSendResult struct {
+	ExternalID   string `json:"external_id"`
+	AccountID    int64  `json:"account_id"`
+	ChannelPoint string `json:"channel_point"`
+	*clients.PaymentResult
+}
+
+// Reconciled payment statuses
+const (
+	PaymentSettled  = "settled"
+	PaymentInFlight = "in_flight"
+	PaymentFailed   = "failed"
+)
+
+// PaymentStatus reconciled from local lnd payment and Xena invoice
+type PaymentStatus struct {
+	PaymentHash  string          `json:"payment_hash"`
+	ExternalID   string          `json:"external_id,omitempty"`
+	AccountID    int64           `json:"account_id,omitempty"`
+	Amount       decimal.Decimal `json:"amount" unit:"btc"`
+	Status       string          `json:"status"`
+	LocalStatus  string          `json:"local_status"`
+	RemoteStatus string          `json:"remote_status"`
+	Mismatch     bool            `json:"mismatch"`
+	Preimage     string          `json:"preimage,omitempty"`
+}
+
+// PaymentSplitStatus of payment split across channels, it's partial if some parts have failed
+type PaymentSplitStatus struct {
+	ExternalID string           `json:"external_id"`
+	AccountID  int64            `json:"account_id"`
+	Amount     decimal.Decimal  `json:"amount" unit:"btc"`
+	Status     string           `json:"status"`
+	Mismatch   bool             `json:"mismatch"`
+	Parts      []*PaymentStatus `json:"parts"`
+}
+
 // paymentList command handler
 func paymentList(c *cli.Context) error {
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
@@ -50,8 +119,7 @@ func paymentList(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	ResponseJSON(res)
-	return nil
+	return Response(res)
 }
 
 // paymentSend command handler
@@ -65,33 +133,689 @@ func paymentSend(c *cli.Context) error {
 	// Parse and validate account and amount
 	account := c.Int64("account")
 	if account <= 0 {
-		return fmt.Errorf("Invalid account")
+		return usageError("Invalid account")
 	}
-	amount, err := decimal.NewFromString(c.String("amount"))
+	amount, err := parseAmount(c, "amount", satoshiPrecision)
 	if err != nil {
-		return fmt.Errorf("Invalid amount value")
+		return err
 	}
 	// Parse channel
 	chanID := c.Uint64("channel-id")
 	chanPoint := c.String("channel-point")
-	if chanID == 0 && chanPoint == "" {
-		return fmt.Errorf("Either channel-id or channel-point required")
+	auto := c.Bool("auto")
+	if auto && (chanID != 0 || chanPoint != "") {
+		return usageError("Either auto or channel-id and channel-point allowed")
+	}
+	if !auto && chanID == 0 && chanPoint == "" {
+		return usageError("Either channel-id, channel-point or auto required")
+	}
+	externalID := c.String("external-id")
+	if externalID == "" {
+		externalID = time.Now().UTC().String()
+	}
+
+	// Check journal for the earlier attempt with the same external id
+	jrn, err := journal.Open(c.GlobalString("journal"))
+	if err != nil {
+		return err
+	}
+	defer jrn.Close()
+	parts := jrn.Find(func(e *journal.Entry) bool {
+		return e.AccountID == account && strings.HasPrefix(e.ExternalID, externalID+"/")
+	})
+	entry := jrn.Get(account, externalID)
+	if auto {
+		if entry != nil {
+			return usageError("External id %s was already used for payment via channel %s", externalID, entry.ChannelPoint)
+		}
+		return paymentSendAuto(c, jrn, account, amount, externalID, parts)
+	}
+	if len(parts) > 0 {
+		return usageError("External id %s was already used for payment split across channels", externalID)
+	}
+	if entry != nil {
+		if !entry.Amount.Equal(amount) {
+			return usageError("External id %s was already used for payment on %s", externalID, formatAmount(entry.Amount))
+		}
+		if entry.Status == journal.StatusSucceeded {
+			return Response(entryResult(entry, nil))
+		}
 	}
 
 	// Get clients
-	restcli, err := clients.NewRestClient(c)
+	restcli, err := Clients.Rest(c)
 	if err != nil {
 		return err
 	}
-	lncli, err := clients.NewLndClient(c, true)
+	lncli, err := Clients.Lnd(c, true)
 	if err != nil {
 		return err
 	}
 
+	// Report the earlier attempt if its invoice has been paid meanwhile or is still in flight
+	if entry != nil {
+		res, err := findEntryPayment(lncli, entry)
+		if err != nil {
+			return err
+		}
+		if res != nil {
+			if err = updateEntry(jrn, entry, res); err != nil {
+				return err
+			}
+			return Response(entryResult(entry, res))
+		}
+	}
+
 	// Find and validate a channel to pay to
+	channel, err := xenaChannel(restcli, lncli, chanID, chanPoint)
+	if err != nil {
+		return err
+	}
+
+	// Get limits from API
+	limits, err := restcli.Limits()
+	if err != nil {
+		return wrapError(err, "getting Limits")
+	}
+	if err = checkPaymentAmount(amount, limits); err != nil {
+		return err
+	}
+	// Check channel's local balance
+	reserved := channel.LocalReserved.Mul(limits.ChannelReserveMultiplier)
+	maxPaymentAmount := channel.LocalBalance.Sub(reserved)
+	if amount.GreaterThan(maxPaymentAmount) {
+		details := struct {
+			LocalBalance     decimal.Decimal `json:"local_balance" unit:"btc"`
+			Reserved         decimal.Decimal `json:"reserved" unit:"btc"`
+			MaxPaymentAmount decimal.Decimal `json:"max_payment_amount" unit:"btc"`
+		}{channel.LocalBalance, reserved, maxPaymentAmount}
+		return newError(CodeInsufficientFunds, nil, details, "Amount %s is greater than (local_balance %s - reserved %s) = %s",
+			formatAmount(amount), formatAmount(channel.LocalBalance), formatAmount(reserved), formatAmount(maxPaymentAmount))
+	}
+
+	// Pay the invoice of the failed earlier attempt again or issue a new one
+	if entry != nil {
+		if entry.ChannelPoint != channel.ChannelPoint {
+			return usageError("External id %s was already used for payment via channel %s", externalID, entry.ChannelPoint)
+		}
+	} else {
+		// Request API for invoice for specified channel
+		invoices, err := restcli.IssueInvoices(account, externalID, []string{channel.ChannelPoint})
+		if err != nil {
+			return wrapError(err, "getting invoices to pay")
+		}
+		if len(invoices) == 0 {
+			return fmt.Errorf("No invoices were returned from IssueInvoices")
+		}
+		inv := invoices[0]
+		hash, err := lncli.PaymentHash(inv.PaymentRequest)
+		if err != nil {
+			return wrapError(err, "decoding payment request")
+		}
+		entry = &journal.Entry{
+			ExternalID:     externalID,
+			AccountID:      account,
+			Amount:         amount,
+			ChannelPoint:   channel.ChannelPoint,
+			PaymentRequest: inv.PaymentRequest,
+			PaymentHash:    hash,
+			Status:         journal.StatusIssued,
+		}
+		if err = jrn.Put(entry); err != nil {
+			return err
+		}
+	}
+
+	// Send payment and wait for its final state
+	res, err := payEntry(lncli, entry, channel.ID, c.Duration("timeout"), false)
+	if res == nil {
+		return err
+	}
+	if jerr := updateEntry(jrn, entry, res); jerr != nil {
+		return jerr
+	}
+	if res.Status == clients.PaymentFailed {
+		// Transport errors are classified from the cause, payment failed is reported by lnd only
+		code := CodePaymentFailed
+		if err != nil {
+			code = ""
+		}
+		return newError(code, err, entryResult(entry, res), "Error %s on sending payment on %s via %s (external id %s)",
+			res.Error, formatAmount(amount), channel.ChannelPoint, externalID)
+	}
+	return Response(entryResult(entry, res))
+}
+
+// Overall status of payment split across channels some parts of which have failed
+const paymentPartial = "partial"
+
+// PaymentSplitResult of payment send command split across channels
+type PaymentSplitResult struct {
+	ExternalID string              `json:"external_id"`
+	AccountID  int64               `json:"account_id"`
+	Amount     decimal.Decimal     `json:"amount" unit:"btc"`
+	Status     string              `json:"status"`
+	Parts      []PaymentSendResult `json:"parts"`
+}
+
+// paymentPart of amount split across channels
+type paymentPart struct {
+	channel *clients.ChannelStatus
+	amount  decimal.Decimal
+}
+
+// paymentSendAuto splits amount across all active channels with Xena lnd nodes and pays the parts concurrently.
+// Parts are journaled under <external id>/<n>, parts of the earlier attempt are resumed
+func paymentSendAuto(c *cli.Context, jrn *journal.Journal, account int64, amount decimal.Decimal, externalID string, parts []*journal.Entry) error {
+	resume := len(parts) > 0
+	if resume {
+		total := decimal.Zero
+		succeeded := true
+		for _, p := range parts {
+			total = total.Add(p.Amount)
+			succeeded = succeeded && p.Status == journal.StatusSucceeded
+		}
+		if !total.Equal(amount) {
+			return usageError("External id %s was already used for payment on %s", externalID, formatAmount(total))
+		}
+		if succeeded {
+			return Response(splitResult(account, amount, externalID, parts, make([]*clients.PaymentResult, len(parts))))
+		}
+	}
+
+	// Get clients
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return err
+	}
+	lncli, err := Clients.Lnd(c, true)
+	if err != nil {
+		return err
+	}
+	channels, err := xenaChannels(restcli, lncli)
+	if err != nil {
+		return err
+	}
+
+	if !resume {
+		limits, err := restcli.Limits()
+		if err != nil {
+			return wrapError(err, "getting Limits")
+		}
+		if err = checkPaymentAmount(amount, limits); err != nil {
+			return err
+		}
+		split, err := splitPayment(amount, limits, channels)
+		if err != nil {
+			return err
+		}
+		// Request API for invoice for every channel
+		chanPoints := make([]string, 0, len(split))
+		amounts := map[string]decimal.Decimal{}
+		for _, p := range split {
+			chanPoints = append(chanPoints, p.channel.ChannelPoint)
+			amounts[p.channel.ChannelPoint] = p.amount
+		}
+		invoices, err := restcli.IssueInvoices(account, externalID, chanPoints)
+		if err != nil {
+			return wrapError(err, "getting invoices to pay")
+		}
+		if len(invoices) != len(split) {
+			return fmt.Errorf("%d invoices were returned from IssueInvoices for %d channels", len(invoices), len(split))
+		}
+		for i, inv := range invoices {
+			partAmount, ok := amounts[inv.ChanPoint]
+			if !ok {
+				return fmt.Errorf("Invoice for unexpected channel %s was returned from IssueInvoices", inv.ChanPoint)
+			}
+			hash, err := lncli.PaymentHash(inv.PaymentRequest)
+			if err != nil {
+				return wrapError(err, "decoding payment request")
+			}
+			entry := &journal.Entry{
+				ExternalID:     fmt.Sprintf("%s/%d", externalID, i+1),
+				AccountID:      account,
+				Amount:         partAmount,
+				ChannelPoint:   inv.ChanPoint,
+				PaymentRequest: inv.PaymentRequest,
+				PaymentHash:    hash,
+				Status:         journal.StatusIssued,
+			}
+			if err = jrn.Put(entry); err != nil {
+				return err
+			}
+			parts = append(parts, entry)
+		}
+	}
+
+	// Send parts concurrently and wait for their final states
+	chanIDs := map[string]uint64{}
+	for _, ch := range channels {
+		chanIDs[ch.ChannelPoint] = ch.ID
+	}
+	results := make([]*clients.PaymentResult, len(parts))
+	errs := make([]error, len(parts))
+	wg := sync.WaitGroup{}
+	for i, p := range parts {
+		if p.Status == journal.StatusSucceeded {
+			continue
+		}
+		chanID, ok := chanIDs[p.ChannelPoint]
+		if !ok {
+			results[i] = &clients.PaymentResult{PaymentHash: p.PaymentHash, Status: clients.PaymentFailed, Amount: p.Amount,
+				Error: fmt.Sprintf("channel %s is not active", p.ChannelPoint)}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, p *journal.Entry, chanID uint64) {
+			defer wg.Done()
+			results[i], errs[i] = payEntry(lncli, p, chanID, c.Duration("timeout"), resume)
+		}(i, p, chanID)
+	}
+	wg.Wait()
+	for i, p := range parts {
+		if p.Status == journal.StatusSucceeded {
+			continue
+		}
+		// Parts whose earlier attempt could not be looked up are looked up again on the next run
+		if results[i] == nil {
+			results[i] = &clients.PaymentResult{PaymentHash: p.PaymentHash, Status: clients.PaymentFailed, Amount: p.Amount, Error: errs[i].Error()}
+		}
+		if err = updateEntry(jrn, p, results[i]); err != nil {
+			return err
+		}
+	}
+
+	res := splitResult(account, amount, externalID, parts, results)
+	if res.Status == clients.PaymentFailed || res.Status == paymentPartial {
+		return newError(CodePaymentFailed, nil, res, "Payment on %s split across %d channels is %s (external id %s)",
+			formatAmount(amount), len(parts), res.Status, externalID)
+	}
+	return Response(res)
+}
+
+// splitResult of payment split across channels, results are built from journal entries where nil
+func splitResult(account int64, amount decimal.Decimal, externalID string, parts []*journal.Entry, results []*clients.PaymentResult) *PaymentSplitResult {
+	res := &PaymentSplitResult{ExternalID: externalID, AccountID: account, Amount: amount, Parts: []PaymentSendResult{}}
+	counts := map[string]int{}
+	for i, p := range parts {
+		r := entryResult(p, results[i])
+		counts[r.Status]++
+		res.Parts = append(res.Parts, r)
+	}
+	switch {
+	case counts[clients.PaymentSucceeded] == len(parts):
+		res.Status = clients.PaymentSucceeded
+	case counts[clients.PaymentFailed] == len(parts):
+		res.Status = clients.PaymentFailed
+	case counts[clients.PaymentFailed] > 0:
+		res.Status = paymentPartial
+	default:
+		res.Status = clients.PaymentInFlight
+	}
+	return res
+}
+
+// splitPayment across channels in proportion to their spendable balances,
+// leaving out the smallest channels while any part is less than min payment amount
+func splitPayment(amount decimal.Decimal, limits *clients.Limits, channels []*clients.ChannelStatus) ([]paymentPart, error) {
+	spendable := map[string]decimal.Decimal{}
+	candidates := []*clients.ChannelStatus{}
+	total := decimal.Zero
+	for _, ch := range channels {
+		s := ch.LocalBalance.Sub(ch.LocalReserved.Mul(limits.ChannelReserveMultiplier))
+		if s.LessThan(limits.MinPaymentAmount) {
+			continue
+		}
+		spendable[ch.ChannelPoint] = s
+		candidates = append(candidates, ch)
+		total = total.Add(s)
+	}
+	if amount.GreaterThan(total) {
+		details := struct {
+			Channels       int             `json:"channels"`
+			TotalSpendable decimal.Decimal `json:"total_spendable" unit:"btc"`
+		}{len(candidates), total}
+		return nil, newError(CodeInsufficientFunds, nil, details, "Amount %s is greater than total spendable balance %s of %d channels",
+			formatAmount(amount), formatAmount(total), len(candidates))
+	}
+	// The largest channel goes last to take the rounding remainder
+	sort.Slice(candidates, func(i, j int) bool {
+		return spendable[candidates[i].ChannelPoint].LessThan(spendable[candidates[j].ChannelPoint])
+	})
+	for len(candidates) > 0 && !amount.GreaterThan(total) {
+		parts := make([]paymentPart, 0, len(candidates))
+		rest := amount
+		for i, ch := range candidates {
+			part := rest
+			if i < len(candidates)-1 {
+				part = amount.Mul(spendable[ch.ChannelPoint]).Div(total).Truncate(satoshiPrecision)
+			}
+			if part.LessThan(limits.MinPaymentAmount) || part.GreaterThan(spendable[ch.ChannelPoint]) {
+				break
+			}
+			rest = rest.Sub(part)
+			parts = append(parts, paymentPart{channel: ch, amount: part})
+		}
+		if len(parts) == len(candidates) {
+			return parts, nil
+		}
+		total = total.Sub(spendable[candidates[0].ChannelPoint])
+		candidates = candidates[1:]
+	}
+	return nil, usageError("Amount %s can not be split across channels into parts not less than min payment amount %s",
+		formatAmount(amount), formatAmount(limits.MinPaymentAmount))
+}
+
+// payEntry sends payment of journal entry via specified channel unless the earlier attempt being resumed
+// has succeeded or is still in flight. Failed result is returned along with the error of sending;
+// result is nil if the earlier attempt could not be looked up
+func payEntry(lncli clients.LndClient, entry *journal.Entry, chanID uint64, timeout time.Duration, resume bool) (*clients.PaymentResult, error) {
+	if resume {
+		res, err := findEntryPayment(lncli, entry)
+		if res != nil || err != nil {
+			return res, err
+		}
+	}
+	res, err := lncli.SendPayment(entry.PaymentRequest, entry.Amount, chanID, timeout)
+	if err != nil {
+		return &clients.PaymentResult{PaymentHash: entry.PaymentHash, Status: clients.PaymentFailed, Amount: entry.Amount, Error: err.Error()}, err
+	}
+	return res, nil
+}
+
+// findEntryPayment of journal entry made by the earlier attempt, nil if it has not been made or has failed
+func findEntryPayment(lncli clients.LndClient, entry *journal.Entry) (*clients.PaymentResult, error) {
+	p, err := lncli.FindPayment(entry.PaymentHash)
+	if err != nil {
+		return nil, wrapError(err, fmt.Sprintf("looking up payment %s", entry.PaymentHash))
+	}
+	if p == nil || p.Status == clients.PaymentFailed {
+		return nil, nil
+	}
+	return &clients.PaymentResult{
+		PaymentHash: p.PaymentHash,
+		Status:      p.Status,
+		Amount:      p.Amount,
+		Fee:         p.Fee,
+		Preimage:    p.Preimage,
+	}, nil
+}
+
+// updateEntry of journal with payment result
+func updateEntry(jrn *journal.Journal, entry *journal.Entry, res *clients.PaymentResult) error {
+	switch res.Status {
+	case clients.PaymentSucceeded:
+		entry.Status = journal.StatusSucceeded
+	case clients.PaymentFailed:
+		entry.Status = journal.StatusFailed
+	default:
+		entry.Status = journal.StatusInFlight
+	}
+	entry.Preimage = res.Preimage
+	entry.Error = res.Error
+	return jrn.Put(entry)
+}
+
+// entryResult of payment send command, res is built from entry if nil
+func entryResult(entry *journal.Entry, res *clients.PaymentResult) PaymentSendResult {
+	if res == nil {
+		res = &clients.PaymentResult{
+			PaymentHash: entry.PaymentHash,
+			Status:      entry.Status,
+			Amount:      entry.Amount,
+			Preimage:    entry.Preimage,
+			Error:       entry.Error,
+		}
+	}
+	return PaymentSendResult{
+		ExternalID:    entry.ExternalID,
+		AccountID:     entry.AccountID,
+		ChannelPoint:  entry.ChannelPoint,
+		PaymentResult: res,
+	}
+}
+
+// paymentStatus command handler
+func paymentStatus(c *cli.Context) error {
+	hash := c.String("hash")
+	externalID := c.String("external-id")
+	if (hash == "") == (externalID == "") {
+		return usageError("Either hash or external-id required")
+	}
+
+	// Find journal entry of the payment or entries of its parts if it was split across channels
+	jrn, err := journal.Open(c.GlobalString("journal"))
+	if err != nil {
+		return err
+	}
+	defer jrn.Close()
+	account := c.Int64("account")
+	entries := jrn.Find(func(e *journal.Entry) bool {
+		if hash != "" {
+			return e.PaymentHash == hash
+		}
+		return e.ExternalID == externalID && (account == 0 || e.AccountID == account)
+	})
+	split := false
+	if hash == "" && len(entries) == 0 {
+		entries = jrn.Find(func(e *journal.Entry) bool {
+			return strings.HasPrefix(e.ExternalID, externalID+"/") && (account == 0 || e.AccountID == account)
+		})
+		split = len(entries) > 0
+	}
+	var entry *journal.Entry
+	switch {
+	case split:
+		for _, e := range entries {
+			if e.AccountID != entries[0].AccountID {
+				return usageError("External id %s was used for several accounts, specify account", externalID)
+			}
+		}
+	case len(entries) == 1:
+		entry = entries[0]
+		hash = entry.PaymentHash
+	case len(entries) > 1:
+		return usageError("External id %s was used for several accounts, specify account", externalID)
+	case hash == "":
+		return usageError("External id %s not found in journal", externalID)
+	}
+
+	// Get clients
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return err
+	}
+	lncli, err := Clients.Lnd(c, true)
+	if err != nil {
+		return err
+	}
+
+	if !split {
+		res, err := reconcile(restcli, lncli, jrn, hash, entry)
+		if err != nil {
+			return err
+		}
+		return Response(res)
+	}
+	res := &PaymentSplitStatus{ExternalID: externalID, AccountID: entries[0].AccountID, Parts: []*PaymentStatus{}}
+	counts := map[string]int{}
+	for _, e := range entries {
+		part, err := reconcile(restcli, lncli, jrn, e.PaymentHash, e)
+		if err != nil {
+			return err
+		}
+		res.Amount = res.Amount.Add(part.Amount)
+		res.Mismatch = res.Mismatch || part.Mismatch
+		res.Parts = append(res.Parts, part)
+		counts[part.Status]++
+	}
+	switch {
+	case counts[PaymentSettled] == len(entries):
+		res.Status = PaymentSettled
+	case counts[PaymentFailed] == len(entries):
+		res.Status = PaymentFailed
+	case counts[PaymentFailed] > 0:
+		res.Status = paymentPartial
+	default:
+		res.Status = PaymentInFlight
+	}
+	return Response(res)
+}
+
+// reconcile payment state of local lnd node with invoice state on Xena side and record it in journal entry if any
+func reconcile(restcli clients.RestClient, lncli clients.LndClient, jrn *journal.Journal, hash string, entry *journal.Entry) (*PaymentStatus, error) {
+	res := &PaymentStatus{PaymentHash: hash, LocalStatus: "not_found", RemoteStatus: "not_found"}
+	if entry != nil {
+		res.ExternalID = entry.ExternalID
+		res.AccountID = entry.AccountID
+		res.Amount = entry.Amount
+	}
+	// Local lnd side
+	p, err := lncli.FindPayment(hash)
+	if err != nil {
+		return nil, wrapError(err, fmt.Sprintf("looking up payment %s", hash))
+	}
+	if p != nil {
+		res.LocalStatus = p.Status
+		res.Amount = p.Amount
+		res.Preimage = p.Preimage
+	}
+	// Xena side
+	inv, err := restcli.InvoiceStatus(hash)
+	if apiErr, ok := err.(*clients.APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+		err = nil
+	} else if err == nil {
+		res.RemoteStatus = inv.Status
+		if res.AccountID == 0 {
+			res.ExternalID = inv.ExternalID
+			res.AccountID = inv.AccountID
+		}
+	}
+	if err != nil {
+		return nil, wrapError(err, fmt.Sprintf("getting invoice %s status", hash))
+	}
+
+	// Funds moved once Xena has settled the invoice, both sides are expected to agree
+	localSucceeded := res.LocalStatus == clients.PaymentSucceeded
+	remoteSettled := res.RemoteStatus == clients.InvoiceSettled
+	switch {
+	case remoteSettled:
+		res.Status = PaymentSettled
+	case localSucceeded || res.LocalStatus == clients.PaymentInFlight:
+		res.Status = PaymentInFlight
+	default:
+		res.Status = PaymentFailed
+	}
+	res.Mismatch = localSucceeded != remoteSettled
+
+	// Record the reconciled state in journal
+	if entry != nil && p != nil {
+		if err = updateEntry(jrn, entry, &clients.PaymentResult{Status: p.Status, Preimage: p.Preimage}); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// PaymentWithdrawResult of payment withdraw command
+type PaymentWithdrawResult struct {
+	WithdrawalID string `json:"withdrawal_id"`
+	AccountID    int64  `json:"account_id"`
+	ChannelPoint string `json:"channel_point"`
+	*clients.LocalInvoice
+}
+
+// paymentWithdraw command handler
+func paymentWithdraw(c *cli.Context) error {
+	// Show command help if no arguments provided
+	if c.NumFlags() == 0 {
+		cli.ShowCommandHelp(c, "withdraw")
+		return nil
+	}
+
+	// Parse and validate account, amount and channel
+	account := c.Int64("account")
+	if account <= 0 {
+		return usageError("Invalid account")
+	}
+	amount, err := parseAmount(c, "amount", satoshiPrecision)
+	if err != nil {
+		return err
+	}
+	chanID := c.Uint64("channel-id")
+	chanPoint := c.String("channel-point")
+	if chanID == 0 && chanPoint == "" {
+		return usageError("Either channel-id or channel-point required")
+	}
+
+	// Get clients
+	restcli, err := Clients.Rest(c)
+	if err != nil {
+		return err
+	}
+	lncli, err := Clients.Lnd(c, true)
+	if err != nil {
+		return err
+	}
+
+	// Find and validate a channel to be paid via
+	channel, err := xenaChannel(restcli, lncli, chanID, chanPoint)
+	if err != nil {
+		return err
+	}
+	limits, err := restcli.Limits()
+	if err != nil {
+		return wrapError(err, "getting Limits")
+	}
+	if err = checkPaymentAmount(amount, limits); err != nil {
+		return err
+	}
+	// Check channel's remote balance
+	reserved := channel.RemoteReserved.Mul(limits.ChannelReserveMultiplier)
+	maxWithdrawalAmount := channel.RemoteBalance.Sub(reserved)
+	if amount.GreaterThan(maxWithdrawalAmount) {
+		details := struct {
+			RemoteBalance       decimal.Decimal `json:"remote_balance" unit:"btc"`
+			Reserved            decimal.Decimal `json:"reserved" unit:"btc"`
+			MaxWithdrawalAmount decimal.Decimal `json:"max_withdrawal_amount" unit:"btc"`
+		}{channel.RemoteBalance, reserved, maxWithdrawalAmount}
+		return newError(CodeInsufficientFunds, nil, details, "Amount %s is greater than (remote_balance %s - reserved %s) = %s",
+			formatAmount(amount), formatAmount(channel.RemoteBalance), formatAmount(reserved), formatAmount(maxWithdrawalAmount))
+	}
+
+	// Create invoice and ask Xena to pay it
+	inv, err := lncli.AddInvoice(amount, fmt.Sprintf("dAccs withdrawal from account %d", account))
+	if err != nil {
+		return wrapError(err, "adding invoice")
+	}
+	wd, err := restcli.RequestWithdrawal(account, inv.PaymentRequest, channel.ChannelPoint)
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("requesting withdrawal of %s from account %d", formatAmount(amount), account))
+	}
+
+	// Wait for the invoice to be paid
+	inv, err = lncli.WaitInvoice(inv.PaymentHash, c.Duration("timeout"))
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("waiting for invoice %s", wd.PaymentHash))
+	}
+	res := PaymentWithdrawResult{
+		WithdrawalID: wd.ID,
+		AccountID:    account,
+		ChannelPoint: channel.ChannelPoint,
+		LocalInvoice: inv,
+	}
+	if inv.Status == clients.InvoiceStatusCanceled {
+		return newError(CodePaymentFailed, nil, res, "Withdrawal %s invoice %s was canceled", wd.ID, inv.PaymentHash)
+	}
+	return Response(res)
+}
+
+// xenaChannel finds active channel by id or channel point and checks it's a channel with Xena lnd node
+func xenaChannel(restcli clients.RestClient, lncli clients.LndClient, chanID uint64, chanPoint string) (*clients.ChannelStatus, error) {
 	channels, err := lncli.ActiveChannels()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting active channels", err)
+		return nil, wrapError(err, "getting active channels")
 	}
 	var channel *clients.ChannelStatus
 	for _, c := range channels {
@@ -105,57 +829,47 @@ func paymentSend(c *cli.Context) error {
 		if p == "" {
 			p = strconv.FormatUint(chanID, 10)
 		}
-		return fmt.Errorf("Channel %s not found", p)
+		return nil, usageError("Channel %s not found", p)
 	}
 	// Check if it's a channel with Xena lnd node
 	addrs, err := restcli.RemoteAddresses()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting RemoteAddresses", err)
-	}
-	found := false
-	for _, a := range addrs {
-		if strings.Contains(a, channel.Node) {
-			found = true
-			break
-		}
+		return nil, wrapError(err, "getting RemoteAddresses")
 	}
-	if !found {
-		return fmt.Errorf("Specified channel should be an open active channel with Xena lnd node")
+	if !isXenaNode(addrs, channel.Node) {
+		return nil, usageError("Specified channel should be an open active channel with Xena lnd node")
 	}
+	return channel, nil
+}
 
-	// Get limits from API
-	limits, err := restcli.Limits()
+// xenaChannels lists active channels with Xena lnd nodes
+func xenaChannels(restcli clients.RestClient, lncli clients.LndClient) ([]*clients.ChannelStatus, error) {
+	channels, err := lncli.ActiveChannels()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting Limits", err)
-	}
-	if amount.LessThan(limits.MinPaymentAmount) {
-		return fmt.Errorf("Amount should be greater or equal to min payment amount %s", limits.MinPaymentAmount)
-	}
-	// Check channel's local balance
-	reserved := channel.LocalReserved.Mul(limits.ChannelReserveMultiplier)
-	maxPaymentAmount := channel.LocalBalance.Sub(reserved)
-	if amount.GreaterThan(maxPaymentAmount) {
-		return fmt.Errorf("Amount %s is greater than (local_balance %s - reserved %s) = %s",
-			amount, channel.LocalBalance, reserved, maxPaymentAmount)
+		return nil, wrapError(err, "getting active channels")
 	}
-
-	// Request API for invoice for specified channel
-	invoices, err := restcli.IssueInvoices(account, []string{channel.ChannelPoint})
+	addrs, err := restcli.RemoteAddresses()
 	if err != nil {
-		return fmt.Errorf("Error %s on getting invoices to pay", err)
+		return nil, wrapError(err, "getting RemoteAddresses")
+	}
+	res := []*clients.ChannelStatus{}
+	for _, ch := range channels {
+		if isXenaNode(addrs, ch.Node) {
+			res = append(res, ch)
+		}
 	}
-	if len(invoices) == 0 {
-		return fmt.Errorf("No invoices were returned from IssueInvoices")
+	if len(res) == 0 {
+		return nil, usageError("No active channels with Xena lnd nodes")
 	}
-	inv := invoices[0]
+	return res, nil
+}
 
-	// Send payment
-	err = lncli.SendPayment(inv.PaymentRequest, amount, channel.ID)
-	if err != nil {
-		fmt.Println(fmt.Sprintf("%#v", err))
-		msg := fmt.Sprintf("Error %s on sending payment on %s to %s %s", err, amount, inv.NodeID, channel.ChannelPoint)
-		ResponseError(Error{Error: msg})
-		os.Exit(1)
+// isXenaNode checks if node pubkey is one of Xena lnd node addresses
+func isXenaNode(addrs []string, node string) bool {
+	for _, a := range addrs {
+		if strings.Contains(a, node) {
+			return true
+		}
 	}
-	return nil
+	return false
 }
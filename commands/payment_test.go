@@ -0,0 +1,493 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+	"github.com/xenaex/daccs-cli/clients/fake"
+	"github.com/xenaex/daccs-cli/journal"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPaymentSend(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+
+	args := []string{"payment", "send", "--account", "1", "--amount", "0.001", "--channel-id", "1", "--external-id", "dep-1"}
+	stdout, _, err := e.run(args...)
+	require.NoError(t, err)
+	var res PaymentSendResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "dep-1", res.ExternalID)
+	assert.Equal(t, int64(1), res.AccountID)
+	assert.Equal(t, "tx1:0", res.ChannelPoint)
+	assert.Equal(t, clients.PaymentSucceeded, res.Status)
+	assert.Equal(t, "0.001", res.Amount.String())
+	assert.Equal(t, e.lnd.PaymentList[0].Preimage, res.Preimage)
+	assert.NotEqual(t, "", res.Preimage)
+	assert.Equal(t, "0.009", e.lnd.ChannelList[0].LocalBalance.String())
+	require.Len(t, e.rest.IssuedInvoices, 1)
+	assert.Equal(t, "tx1:0", e.rest.IssuedInvoices[0].Invoice.ChanPoint)
+
+	// Repeated external id reports the earlier payment instead of paying again
+	stdout, _, err = e.run(args...)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, clients.PaymentSucceeded, res.Status)
+	assert.Len(t, e.rest.IssuedInvoices, 1)
+	assert.Len(t, e.lnd.PaymentList, 1)
+
+	_, _, err = e.run("payment", "send", "--account", "1", "--amount", "0.002", "--channel-id", "1", "--external-id", "dep-1")
+	require.Error(t, err)
+	assert.Equal(t, "External id dep-1 was already used for payment on 0.001", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+}
+
+func TestPaymentSendResume(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+
+	args := []string{"payment", "send", "--account", "1", "--amount", "0.005", "--channel-id", "1", "--external-id", "dep-1"}
+	_, _, err := e.run(args...)
+	require.NoError(t, err)
+
+	// Journal was not updated after the payment, channel balance does not allow to pay it again
+	jrn, err := journal.Open(e.journal)
+	require.NoError(t, err)
+	entry := jrn.Get(1, "dep-1")
+	require.NotNil(t, entry)
+	entry.Status = journal.StatusInFlight
+	entry.Preimage = ""
+	require.NoError(t, jrn.Put(entry))
+	require.NoError(t, jrn.Close())
+	assert.Equal(t, "0.005", e.lnd.ChannelList[0].LocalBalance.String())
+
+	stdout, _, err := e.run(args...)
+	require.NoError(t, err)
+	var res PaymentSendResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, clients.PaymentSucceeded, res.Status)
+	assert.NotEqual(t, "", res.Preimage)
+	assert.Len(t, e.lnd.PaymentList, 1)
+	assert.Len(t, e.rest.IssuedInvoices, 1)
+
+	jrn, err = journal.Open(e.journal)
+	require.NoError(t, err)
+	defer jrn.Close()
+	entry = jrn.Get(1, "dep-1")
+	assert.Equal(t, journal.StatusSucceeded, entry.Status)
+
+	// Failed earlier attempt is paid again after validation of the channel balance
+	e.lnd.PaymentList[0].Status = clients.PaymentFailed
+	entry.Status = journal.StatusFailed
+	require.NoError(t, jrn.Put(entry))
+	_, _, err = e.run(args...)
+	require.Error(t, err)
+	assert.Equal(t, 7, ExitCode(err))
+}
+
+func TestPaymentSendAuto(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = append(testChannels(), &clients.ChannelStatus{ID: 4, Node: fake.RemotePubKey, ChannelPoint: "tx4:0",
+		Status: "active", Capacity: btc("0.01"), LocalBalance: btc("0.01"), LocalReserved: btc("0.0002")})
+
+	stdout, _, err := e.run("payment", "send", "--account", "1", "--amount", "0.01", "--auto", "--external-id", "dep-1")
+	require.NoError(t, err)
+	var res PaymentSplitResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, clients.PaymentSucceeded, res.Status)
+	require.Len(t, res.Parts, 2)
+	for i, p := range res.Parts {
+		assert.Equal(t, clients.PaymentSucceeded, p.Status)
+		assert.Equal(t, "0.005", p.Amount.String())
+		assert.Equal(t, []string{"dep-1/1", "dep-1/2"}[i], p.ExternalID)
+	}
+	assert.Len(t, e.lnd.PaymentList, 2)
+
+	// Repeated external id reports the earlier parts instead of paying again
+	stdout, _, err = e.run("payment", "send", "--account", "1", "--amount", "0.01", "--auto", "--external-id", "dep-1")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, clients.PaymentSucceeded, res.Status)
+	assert.Len(t, e.lnd.PaymentList, 2)
+	assert.Len(t, e.rest.IssuedInvoices, 2)
+
+	_, _, err = e.run("payment", "send", "--account", "1", "--amount", "0.01", "--channel-id", "1", "--external-id", "dep-1")
+	require.Error(t, err)
+	assert.Equal(t, "External id dep-1 was already used for payment split across channels", err.Error())
+}
+
+func TestPaymentSendErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(e *testEnv)
+		args    []string
+		exit    int
+		message string
+	}{
+		{
+			name:    "no account",
+			args:    []string{"--amount", "0.001", "--channel-id", "1"},
+			exit:    2,
+			message: "Invalid account",
+		},
+		{
+			name:    "no channel",
+			args:    []string{"--account", "1", "--amount", "0.001"},
+			exit:    2,
+			message: "Either channel-id, channel-point or auto required",
+		},
+		{
+			name:    "auto with channel",
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-id", "1", "--auto"},
+			exit:    2,
+			message: "Either auto or channel-id and channel-point allowed",
+		},
+		{
+			name:    "amount precision",
+			args:    []string{"--account", "1", "--amount", "0.000000001", "--channel-id", "1"},
+			exit:    2,
+			message: "amount 0.000000001 should be a multiple of 0.00000001",
+		},
+		{
+			name:    "amount below min",
+			args:    []string{"--account", "1", "--amount", "0.000001", "--channel-id", "1"},
+			exit:    2,
+			message: "Amount should be greater or equal to min payment amount 0.00001",
+		},
+		{
+			name:    "unknown channel",
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-id", "9"},
+			exit:    2,
+			message: "Channel 9 not found",
+		},
+		{
+			name:    "not xena channel",
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-point", "tx3:0"},
+			exit:    2,
+			message: "Specified channel should be an open active channel with Xena lnd node",
+		},
+		{
+			name:    "insufficient funds",
+			args:    []string{"--account", "1", "--amount", "0.0099", "--channel-id", "1"},
+			exit:    7,
+			message: "Amount 0.0099 is greater than (local_balance 0.01 - reserved 0.0002) = 0.0098",
+		},
+		{
+			name:    "wallet locked",
+			setup:   func(e *testEnv) { e.lnd.Locked = true },
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-id", "1"},
+			exit:    6,
+			message: "wallet is locked",
+		},
+		{
+			name: "api unauthorized",
+			setup: func(e *testEnv) {
+				e.rest.Errors["IssueInvoices"] = &clients.APIError{StatusCode: 401, Method: "POST", Path: "invoices"}
+			},
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-id", "1"},
+			exit:    3,
+			message: "on getting invoices to pay",
+		},
+		{
+			name:    "lnd unavailable",
+			setup:   func(e *testEnv) { e.lnd.Errors["SendPayment"] = status.Error(codes.Unavailable, "connection refused") },
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-id", "1"},
+			exit:    5,
+			message: "connection refused",
+		},
+		{
+			name: "payment failed",
+			setup: func(e *testEnv) {
+				// Channel without id is not routable by fake lnd
+				e.lnd.ChannelList = append(e.lnd.ChannelList, &clients.ChannelStatus{Node: fake.RemotePubKey, ChannelPoint: "tx4:0",
+					Status: "active", Capacity: btc("0.01"), LocalBalance: btc("0.01")})
+			},
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-point", "tx4:0"},
+			exit:    8,
+			message: "Error unable to find a path to destination on sending payment on 0.001 via tx4:0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			defer e.close()
+			e.lnd.ChannelList = testChannels()
+			if tt.setup != nil {
+				tt.setup(e)
+			}
+			stdout, _, err := e.run(append([]string{"payment", "send"}, tt.args...)...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+			assert.Equal(t, tt.exit, ExitCode(err))
+			assert.Equal(t, "", stdout)
+			assert.Len(t, e.lnd.PaymentList, 0)
+		})
+	}
+}
+
+func TestSplitPayment(t *testing.T) {
+	limits := &clients.Limits{MinPaymentAmount: btc("0.00001"), ChannelReserveMultiplier: btc("1")}
+	channel := func(point, local, reserved string) *clients.ChannelStatus {
+		return &clients.ChannelStatus{ChannelPoint: point, LocalBalance: btc(local), LocalReserved: btc(reserved)}
+	}
+	tests := []struct {
+		name     string
+		amount   string
+		channels []*clients.ChannelStatus
+		parts    map[string]string
+		code     string
+	}{
+		{
+			name:     "single channel",
+			amount:   "0.005",
+			channels: []*clients.ChannelStatus{channel("a:0", "0.01", "0.0001")},
+			parts:    map[string]string{"a:0": "0.005"},
+		},
+		{
+			name:     "proportional to spendable",
+			amount:   "0.02",
+			channels: []*clients.ChannelStatus{channel("a:0", "0.0301", "0.0001"), channel("b:0", "0.0101", "0.0001")},
+			parts:    map[string]string{"a:0": "0.015", "b:0": "0.005"},
+		},
+		{
+			name:     "truncation remainder to the largest",
+			amount:   "0.01",
+			channels: []*clients.ChannelStatus{channel("a:0", "0.01", "0"), channel("b:0", "0.01", "0"), channel("c:0", "0.0101", "0")},
+			parts:    map[string]string{"a:0": "0.00332225", "b:0": "0.00332225", "c:0": "0.0033555"},
+		},
+		{
+			name:     "small channel left out",
+			amount:   "0.00003",
+			channels: []*clients.ChannelStatus{channel("a:0", "0.1", "0"), channel("b:0", "0.00002", "0")},
+			parts:    map[string]string{"a:0": "0.00003"},
+		},
+		{
+			name:     "channel below min left out",
+			amount:   "0.001",
+			channels: []*clients.ChannelStatus{channel("a:0", "0.01", "0"), channel("b:0", "0.00001", "0.000001")},
+			parts:    map[string]string{"a:0": "0.001"},
+		},
+		{
+			name:     "insufficient funds",
+			amount:   "0.03",
+			channels: []*clients.ChannelStatus{channel("a:0", "0.01", "0.0001"), channel("b:0", "0.01", "0.0001")},
+			code:     CodeInsufficientFunds,
+		},
+		{
+			name:     "parts below min",
+			amount:   "0.000015",
+			channels: []*clients.ChannelStatus{channel("a:0", "0.00001", "0"), channel("b:0", "0.00001", "0")},
+			code:     CodeUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := splitPayment(btc(tt.amount), limits, tt.channels)
+			if tt.code != "" {
+				require.Error(t, err)
+				code, _ := classify(err)
+				assert.Equal(t, tt.code, code)
+				return
+			}
+			require.NoError(t, err)
+			res := map[string]string{}
+			total := decimal.Zero
+			for _, p := range parts {
+				res[p.channel.ChannelPoint] = p.amount.String()
+				total = total.Add(p.amount)
+			}
+			assert.Equal(t, tt.parts, res)
+			assert.Equal(t, tt.amount, total.String())
+		})
+	}
+}
+
+func TestPaymentStatus(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+	_, _, err := e.run("payment", "send", "--account", "1", "--amount", "0.001", "--channel-id", "1", "--external-id", "dep-1")
+	require.NoError(t, err)
+
+	// Paid locally, not settled by Xena yet
+	stdout, _, err := e.run("payment", "status", "--external-id", "dep-1")
+	require.NoError(t, err)
+	var res PaymentStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, e.lnd.PaymentList[0].PaymentHash, res.PaymentHash)
+	assert.Equal(t, "dep-1", res.ExternalID)
+	assert.Equal(t, int64(1), res.AccountID)
+	assert.Equal(t, PaymentInFlight, res.Status)
+	assert.Equal(t, clients.PaymentSucceeded, res.LocalStatus)
+	assert.Equal(t, clients.InvoiceOpen, res.RemoteStatus)
+	assert.True(t, res.Mismatch)
+
+	e.rest.IssuedInvoices[0].Status = clients.InvoiceSettled
+	stdout, _, err = e.run("payment", "status", "--hash", res.PaymentHash)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "dep-1", res.ExternalID)
+	assert.Equal(t, PaymentSettled, res.Status)
+	assert.False(t, res.Mismatch)
+	assert.NotEqual(t, "", res.Preimage)
+
+	// Unknown to both sides
+	stdout, _, err = e.run("payment", "status", "--hash", "00ff")
+	require.NoError(t, err)
+	res = PaymentStatus{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, PaymentFailed, res.Status)
+	assert.Equal(t, "not_found", res.LocalStatus)
+	assert.Equal(t, "not_found", res.RemoteStatus)
+
+	_, _, err = e.run("payment", "status", "--external-id", "dep-2")
+	require.Error(t, err)
+	assert.Equal(t, "External id dep-2 not found in journal", err.Error())
+	assert.Equal(t, 2, ExitCode(err))
+	_, _, err = e.run("payment", "status", "--external-id", "dep-1", "--hash", res.PaymentHash)
+	require.Error(t, err)
+	assert.Equal(t, 2, ExitCode(err))
+}
+
+func TestPaymentStatusSplit(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = append(testChannels(), &clients.ChannelStatus{ID: 4, Node: fake.RemotePubKey, ChannelPoint: "tx4:0",
+		Status: "active", Capacity: btc("0.01"), LocalBalance: btc("0.01"), LocalReserved: btc("0.0002")})
+	_, _, err := e.run("payment", "send", "--account", "1", "--amount", "0.01", "--auto", "--external-id", "dep-1")
+	require.NoError(t, err)
+	require.Len(t, e.rest.IssuedInvoices, 2)
+
+	// Parts are reported together, one of them settled by Xena
+	e.rest.IssuedInvoices[0].Status = clients.InvoiceSettled
+	stdout, _, err := e.run("payment", "status", "--external-id", "dep-1")
+	require.NoError(t, err)
+	var res PaymentSplitStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "dep-1", res.ExternalID)
+	assert.Equal(t, int64(1), res.AccountID)
+	assert.Equal(t, "0.01", res.Amount.String())
+	assert.Equal(t, PaymentInFlight, res.Status)
+	assert.True(t, res.Mismatch)
+	require.Len(t, res.Parts, 2)
+	assert.Equal(t, "dep-1/1", res.Parts[0].ExternalID)
+	assert.Equal(t, PaymentSettled, res.Parts[0].Status)
+	assert.Equal(t, "dep-1/2", res.Parts[1].ExternalID)
+	assert.Equal(t, PaymentInFlight, res.Parts[1].Status)
+
+	e.rest.IssuedInvoices[1].Status = clients.InvoiceSettled
+	stdout, _, err = e.run("payment", "status", "--external-id", "dep-1", "--account", "1")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, PaymentSettled, res.Status)
+	assert.False(t, res.Mismatch)
+
+	// Single part is reported by its own external id
+	stdout, _, err = e.run("payment", "status", "--external-id", "dep-1/2")
+	require.NoError(t, err)
+	var part PaymentStatus
+	require.NoError(t, json.Unmarshal([]byte(stdout), &part))
+	assert.Equal(t, "dep-1/2", part.ExternalID)
+	assert.Equal(t, PaymentSettled, part.Status)
+
+	_, _, err = e.run("payment", "status", "--external-id", "dep-1", "--account", "2")
+	require.Error(t, err)
+	assert.Equal(t, "External id dep-1 not found in journal", err.Error())
+}
+
+func TestPaymentWithdraw(t *testing.T) {
+	e := newTestEnv(t)
+	defer e.close()
+	e.lnd.ChannelList = testChannels()
+	e.lnd.SettleInvoices = true
+
+	stdout, _, err := e.run("payment", "withdraw", "--account", "1", "--amount", "0.002", "--channel-id", "1")
+	require.NoError(t, err)
+	var res PaymentWithdrawResult
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "w1", res.WithdrawalID)
+	assert.Equal(t, int64(1), res.AccountID)
+	assert.Equal(t, "tx1:0", res.ChannelPoint)
+	assert.Equal(t, clients.InvoiceStatusSettled, res.Status)
+	assert.Equal(t, "0.002", res.Amount.String())
+	assert.Equal(t, "0.002", res.AmountPaid.String())
+	require.Len(t, e.rest.Withdrawals, 1)
+	assert.Equal(t, "tx1:0", e.rest.Withdrawals[0].ChanPoint)
+	assert.Equal(t, res.PaymentRequest, e.rest.Withdrawals[0].PaymentRequest)
+
+	// Invoice not paid in time is reported open
+	e.lnd.SettleInvoices = false
+	stdout, _, err = e.run("payment", "withdraw", "--account", "1", "--amount", "0.002", "--channel-point", "tx1:0", "--timeout", "1s")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &res))
+	assert.Equal(t, "w2", res.WithdrawalID)
+	assert.Equal(t, clients.InvoiceStatusOpen, res.Status)
+}
+
+func TestPaymentWithdrawErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(e *testEnv)
+		args    []string
+		exit    int
+		message string
+	}{
+		{
+			name:    "no account",
+			args:    []string{"--amount", "0.001", "--channel-id", "1"},
+			exit:    2,
+			message: "Invalid account",
+		},
+		{
+			name:    "no channel",
+			args:    []string{"--account", "1", "--amount", "0.001"},
+			exit:    2,
+			message: "Either channel-id or channel-point required",
+		},
+		{
+			name:    "inactive channel",
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-id", "2"},
+			exit:    2,
+			message: "Channel 2 not found",
+		},
+		{
+			name:    "insufficient remote balance",
+			args:    []string{"--account", "1", "--amount", "0.0101", "--channel-id", "1"},
+			exit:    7,
+			message: "Amount 0.0101 is greater than (remote_balance 0.01 - reserved 0) = 0.01",
+		},
+		{
+			name: "api unavailable",
+			setup: func(e *testEnv) {
+				e.rest.Errors["RequestWithdrawal"] = &clients.APIError{StatusCode: 503, Method: "POST", Path: "withdrawals"}
+			},
+			args:    []string{"--account", "1", "--amount", "0.001", "--channel-id", "1"},
+			exit:    4,
+			message: "on requesting withdrawal of 0.001 from account 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			defer e.close()
+			e.lnd.ChannelList = testChannels()
+			if tt.setup != nil {
+				tt.setup(e)
+			}
+			stdout, _, err := e.run(append([]string{"payment", "withdraw"}, tt.args...)...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+			assert.Equal(t, tt.exit, ExitCode(err))
+			assert.Equal(t, "", stdout)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/secret"
+)
+
+// Secret commands definition
+var Secret = cli.Command{
+	Name:  "secret",
+	Usage: "API secret local storage commands",
+	Subcommands: []cli.Command{
+		{
+			Name:   "import",
+			Usage:  "Store API secret (from --api-secret or prompted) encrypted with passphrase",
+			Action: secretImport,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "out", Usage: "Path of encrypted secret file (default ~/.config/daccs-cli/secrets/<profile>.json)"},
+			},
+		},
+		{
+			Name:   "agent",
+			Usage:  "Decrypt --api-secret-file once and serve the secret to other commands over unix socket",
+			Action: secretAgent,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "socket", Usage: "Path of agent socket (default ~/.config/daccs-cli/agent.sock)"},
+			},
+		},
+	},
+}
+
+// readPassword from terminal without echo, replaced in tests
+var readPassword = secret.ReadPassword
+
+// secretImport command handler
+func secretImport(c *cli.Context) error {
+	// Get and validate secret
+	secretHex := c.GlobalString("api-secret")
+	if secretHex == "" {
+		s, err := readPassword("Input API secret (hex): ")
+		if err != nil {
+			return err
+		}
+		secretHex = strings.TrimSpace(string(s))
+	}
+	privKeyData, err := hex.DecodeString(secretHex)
+	if err != nil {
+		return usageError("Error %s on decoding api-secret", err)
+	}
+	if _, err = x509.ParseECPrivateKey(privKeyData); err != nil {
+		return usageError("Error %s on parsing api-secret", err)
+	}
+
+	path := c.String("out")
+	if path == "" {
+		path, err = secret.DefaultPath(c.GlobalString("profile"))
+		if err != nil {
+			return wrapError(err, "resolving secret file path")
+		}
+	}
+	if err = storeSecret(privKeyData, path); err != nil {
+		return err
+	}
+	return Response(struct {
+		APISecretFile string `json:"api_secret_file"`
+	}{path})
+}
+
+// storeSecret encrypted with passphrase prompted twice
+func storeSecret(data []byte, path string) error {
+	pwd, err := readPassword("Input passphrase to encrypt API secret with: ")
+	if err != nil {
+		return err
+	}
+	if len(pwd) == 0 {
+		return usageError("Empty passphrase is not allowed")
+	}
+	confirm, err := readPassword("Repeat passphrase: ")
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(pwd, confirm) {
+		return usageError("Passphrases do not match")
+	}
+	f, err := secret.Encrypt(data, pwd)
+	if err != nil {
+		return wrapError(err, "encrypting API secret")
+	}
+	return f.Save(path)
+}
+
+// secretAgent command handler
+func secretAgent(c *cli.Context) error {
+	path := c.GlobalString("api-secret-file")
+	if path == "" {
+		return usageError("api-secret-file is not specified")
+	}
+	privKeyData, err := secret.LoadAndDecrypt(path)
+	if err != nil {
+		return err
+	}
+	socket := c.String("socket")
+	if socket == "" {
+		socket, err = secret.DefaultAgentSocket()
+		if err != nil {
+			return wrapError(err, "resolving agent socket path")
+		}
+	}
+	agent, err := secret.NewAgent(socket, privKeyData)
+	if err != nil {
+		return err
+	}
+
+	// Close agent and remove its socket on termination
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		agent.Close()
+	}()
+
+	err = Response(struct {
+		APISecretAgent string `json:"api_secret_agent"`
+	}{socket})
+	if err != nil {
+		return err
+	}
+	agent.Serve()
+	return nil
+}
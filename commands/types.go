@@ -7,29 +7,39 @@ import (
 	"os"
 )
 
-// Error descriptor
+// Error envelope
 type Error struct {
-	Error string `json:"error"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 // ResponseJSON formatter
-func ResponseJSON(res interface{}) {
+func ResponseJSON(res interface{}) error {
 	data, err := json.Marshal(res)
 	if err != nil {
-		ResponseError(err)
-		return
+		return wrapError(err, "encoding response")
 	}
 	buf := bytes.Buffer{}
 	json.Indent(&buf, data, "", "\t")
 	buf.WriteString("\n")
-	buf.WriteTo(os.Stdout)
+	if _, err = buf.WriteTo(os.Stdout); err != nil {
+		return wrapError(err, "writing response")
+	}
+	return nil
 }
 
-// ResponseError error handler
-func ResponseError(res interface{}) {
+// ResponseError error handler writing error envelope to stderr
+func ResponseError(err error) {
+	code, details := classify(err)
+	// Amounts of details are in amount unit like in responses
+	if d, e := inUnit(details); e == nil {
+		details = d
+	}
+	res := &Error{Code: code, Message: err.Error(), Details: details}
 	data, e := json.Marshal(res)
 	if e != nil {
-		fmt.Fprintf(os.Stderr, "%v (%v)\n", e, res)
+		fmt.Fprintf(os.Stderr, "%v (%v)\n", e, err)
 		return
 	}
 	buf := bytes.Buffer{}
@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Amount units
+const (
+	UnitBTC  = "btc"
+	UnitMBTC = "mbtc"
+	UnitBits = "bits"
+	UnitSat  = "sat"
+)
+
+// Units supported
+var Units = []string{UnitBTC, UnitMBTC, UnitBits, UnitSat}
+
+// unitShifts of decimal point converting BTC to unit
+var unitShifts = map[string]int32{
+	UnitBTC:  0,
+	UnitMBTC: 3,
+	UnitBits: 6,
+	UnitSat:  8,
+}
+
+// unitSuffixes accepted in amount values
+var unitSuffixes = map[string]string{
+	"btc":  UnitBTC,
+	"mbtc": UnitMBTC,
+	"bit":  UnitBits,
+	"bits": UnitBits,
+	"sat":  UnitSat,
+	"sats": UnitSat,
+}
+
+// amountPattern of amount value with optional unit suffix
+var amountPattern = regexp.MustCompile(`^\s*([-+]?[0-9]*\.?[0-9]+)\s*([a-zA-Z]*)\s*$`)
+
+// amountUnit of amount flags and command responses
+var amountUnit = UnitBTC
+
+// decimalType of amounts to be converted
+var decimalType = reflect.TypeOf(decimal.Decimal{})
+
+// Struct tag values of unit key: decimal fields tagged with unit:"btc" are amounts in BTC,
+// string field tagged with unit:"currency" holds the currency of the struct amounts instead
+const (
+	unitTagBTC      = "btc"
+	unitTagCurrency = "currency"
+)
+
+// SetUnit of amount flags and command responses
+func SetUnit(unit string) error {
+	unit = strings.ToLower(unit)
+	if _, ok := unitShifts[unit]; !ok {
+		return usageError("Unknown unit %s, expected one of %s", unit, strings.Join(Units, ", "))
+	}
+	amountUnit = unit
+	return nil
+}
+
+// parseUnitAmount in BTC from value in amount unit or in unit given by suffix, e.g. 250000sat or 2.5mBTC
+func parseUnitAmount(value string) (decimal.Decimal, error) {
+	m := amountPattern.FindStringSubmatch(value)
+	if m == nil {
+		return decimal.Zero, usageError("Invalid amount %s", value)
+	}
+	unit := amountUnit
+	if m[2] != "" {
+		u, ok := unitSuffixes[strings.ToLower(m[2])]
+		if !ok {
+			return decimal.Zero, usageError("Unknown unit %s of amount %s, expected one of %s", m[2], value, strings.Join(Units, ", "))
+		}
+		unit = u
+	}
+	amount, err := decimal.NewFromString(m[1])
+	if err != nil {
+		return decimal.Zero, usageError("Invalid amount %s", value)
+	}
+	return amount.Mul(decimal.New(1, -unitShifts[unit])), nil
+}
+
+// formatAmount in BTC for messages in amount unit, unit suffix is added unless it's BTC
+func formatAmount(amount decimal.Decimal) string {
+	if amountUnit == UnitBTC {
+		return amount.String()
+	}
+	return amount.Mul(decimal.New(1, unitShifts[amountUnit])).String() + " " + amountUnit
+}
+
+// inUnit converts response amounts from BTC to amount unit. Response is converted on its deep copy
+// so the original values are left intact, only decimal fields tagged with unit:"btc" are converted
+func inUnit(res interface{}) (interface{}, error) {
+	if amountUnit == UnitBTC || res == nil {
+		return res, nil
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	cp := reflect.New(reflect.TypeOf(res))
+	if err = json.Unmarshal(data, cp.Interface()); err != nil {
+		return nil, err
+	}
+	shiftAmounts(cp.Elem(), unitShifts[amountUnit])
+	return cp.Elem().Interface(), nil
+}
+
+// shiftAmounts of BTC amount fields of value
+func shiftAmounts(v reflect.Value, shift int32) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			shiftAmounts(v.Elem(), shift)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			shiftAmounts(v.Index(i), shift)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			shiftAmounts(e, shift)
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Struct:
+		btc := inBTC(v)
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if f.Type != decimalType {
+				shiftAmounts(v.Field(i), shift)
+				continue
+			}
+			if btc && isBTCAmount(f) {
+				v.Field(i).Set(reflect.ValueOf(v.Field(i).Interface().(decimal.Decimal).Mul(decimal.New(1, shift))))
+			}
+		}
+	}
+}
+
+// isBTCAmount field of struct
+func isBTCAmount(f reflect.StructField) bool {
+	return f.Type == decimalType && f.Tag.Get("unit") == unitTagBTC
+}
+
+// inBTC checks amounts of struct value are in BTC, that is it has no currency field or its currency is BTC
+func inBTC(v reflect.Value) bool {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.Type.Kind() == reflect.String && f.Tag.Get("unit") == unitTagCurrency {
+			return isBTC(v.Field(i).String())
+		}
+	}
+	return true
+}
+
+// isBTC currency
+func isBTC(currency string) bool {
+	return strings.EqualFold(currency, "BTC")
+}
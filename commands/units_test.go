@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+func TestParseUnitAmount(t *testing.T) {
+	tests := []struct {
+		unit   string
+		value  string
+		amount string
+		err    string
+	}{
+		{UnitBTC, "0.01", "0.01", ""},
+		{UnitBTC, " 1 ", "1", ""},
+		{UnitBTC, ".5", "0.5", ""},
+		{UnitBTC, "250000sat", "0.0025", ""},
+		{UnitBTC, "250000 sats", "0.0025", ""},
+		{UnitBTC, "2.5mBTC", "0.0025", ""},
+		{UnitBTC, "100bits", "0.0001", ""},
+		{UnitBTC, "1bit", "0.000001", ""},
+		{UnitBTC, "-0.01", "-0.01", ""},
+		{UnitSat, "1000", "0.00001", ""},
+		{UnitSat, "0.1BTC", "0.1", ""},
+		{UnitMBTC, "1.5", "0.0015", ""},
+		{UnitBits, "10", "0.00001", ""},
+		{UnitBTC, "", "", "Invalid amount "},
+		{UnitBTC, "abc", "", "Invalid amount abc"},
+		{UnitBTC, "1.2.3", "", "Invalid amount 1.2.3"},
+		{UnitBTC, "1usd", "", "Unknown unit usd of amount 1usd, expected one of btc, mbtc, bits, sat"},
+	}
+	defer SetUnit(UnitBTC)
+	for _, tt := range tests {
+		t.Run(tt.unit+" "+tt.value, func(t *testing.T) {
+			require.NoError(t, SetUnit(tt.unit))
+			amount, err := parseUnitAmount(tt.value)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				assert.Equal(t, CodeUsage, errorCode(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.amount, amount.String())
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		unit   string
+		amount string
+		text   string
+	}{
+		{UnitBTC, "0.0025", "0.0025"},
+		{UnitMBTC, "0.0025", "2.5 mbtc"},
+		{UnitBits, "0.0025", "2500 bits"},
+		{UnitSat, "0.0025", "250000 sat"},
+		{UnitSat, "0.00000000001", "0.001 sat"},
+	}
+	defer SetUnit(UnitBTC)
+	for _, tt := range tests {
+		t.Run(tt.unit+" "+tt.amount, func(t *testing.T) {
+			require.NoError(t, SetUnit(tt.unit))
+			assert.Equal(t, tt.text, formatAmount(btc(tt.amount)))
+		})
+	}
+}
+
+func TestInUnit(t *testing.T) {
+	defer SetUnit(UnitBTC)
+	require.NoError(t, SetUnit(UnitSat))
+	res := struct {
+		Balances []*clients.AccountBalance `json:"balances"`
+		Price    *clients.MarkPrice        `json:"price"`
+		Limits   clients.Limits            `json:"limits"`
+		Channels []*clients.ChannelStatus  `json:"channels"`
+	}{
+		Balances: []*clients.AccountBalance{
+			{AccountID: 1, Currency: "BTC", Total: btc("0.01"), Available: btc("0.005")},
+			{AccountID: 2, Currency: "USD", Total: btc("150.5"), Available: btc("100")},
+		},
+		Price:    &clients.MarkPrice{Symbol: "XBTUSD", Price: btc("9000")},
+		Limits:   clients.Limits{MinChannelCapacity: btc("0.001"), ChannelReserveMultiplier: btc("1.5")},
+		Channels: testChannels()[:1],
+	}
+
+	converted, err := inUnit(res)
+	require.NoError(t, err)
+	data, err := json.Marshal(converted)
+	require.NoError(t, err)
+	var v struct {
+		Balances []*clients.AccountBalance `json:"balances"`
+		Price    *clients.MarkPrice        `json:"price"`
+		Limits   clients.Limits            `json:"limits"`
+		Channels []*clients.ChannelStatus  `json:"channels"`
+	}
+	require.NoError(t, json.Unmarshal(data, &v))
+	assert.Equal(t, "1000000", v.Balances[0].Total.String())
+	assert.Equal(t, "500000", v.Balances[0].Available.String())
+	assert.Equal(t, "150.5", v.Balances[1].Total.String())
+	assert.Equal(t, "100", v.Balances[1].Available.String())
+	assert.Equal(t, "9000", v.Price.Price.String())
+	assert.Equal(t, "100000", v.Limits.MinChannelCapacity.String())
+	assert.Equal(t, "1.5", v.Limits.ChannelReserveMultiplier.String())
+	assert.Equal(t, "2000000", v.Channels[0].Capacity.String())
+	assert.Equal(t, "1000000", v.Channels[0].LocalBalance.String())
+
+	// The original response is left intact
+	assert.Equal(t, "0.01", res.Balances[0].Total.String())
+}
+
+// errorCode of classified error
+func errorCode(err error) string {
+	code, _ := classify(err)
+	return code
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+const (
+	// channelFundingPrecision of channel capacity in BTC
+	channelFundingPrecision = int32(3)
+	// satoshiPrecision of payment amounts in BTC, finer values are truncated on conversion to satoshis
+	satoshiPrecision = int32(8)
+	// millisatoshiPrecision of HTLC amounts in BTC
+	millisatoshiPrecision = int32(11)
+)
+
+// parseAmount of named flag given in amount unit or with unit suffix, converted to BTC.
+// It should be positive and have no more decimal places in BTC than precision
+func parseAmount(c *cli.Context, name string, precision int32) (decimal.Decimal, error) {
+	value := c.String(name)
+	if value == "" {
+		return decimal.Zero, usageError("%s is required", name)
+	}
+	amount, err := parseUnitAmount(value)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount, checkAmount(name, amount, precision)
+}
+
+// checkAmount is positive and has no more decimal places than precision
+func checkAmount(name string, amount decimal.Decimal, precision int32) error {
+	if amount.Sign() <= 0 {
+		return usageError("%s should be positive", name)
+	}
+	if !amount.Equal(amount.Truncate(precision)) {
+		return usageError("%s %s should be a multiple of %s", name, formatAmount(amount), formatAmount(decimal.New(1, -precision)))
+	}
+	return nil
+}
+
+// checkPaymentAmount against API limits
+func checkPaymentAmount(amount decimal.Decimal, limits *clients.Limits) error {
+	if amount.LessThan(limits.MinPaymentAmount) {
+		return usageError("Amount should be greater or equal to min payment amount %s", formatAmount(limits.MinPaymentAmount))
+	}
+	return nil
+}
+
+// checkChannelCapacity against API limits
+func checkChannelCapacity(capacity decimal.Decimal, limits *clients.Limits) error {
+	if capacity.LessThan(limits.MinChannelCapacity) {
+		return usageError("Capacity should be greater than or equal %s", formatAmount(limits.MinChannelCapacity))
+	}
+	return nil
+}
+
+// checkOpenParams of channel with specified capacity against API limits
+func checkOpenParams(params clients.OpenChannelParams, capacity decimal.Decimal, limits *clients.Limits) error {
+	l := limits.ChannelOpen
+	if params.SatPerByte < 0 || params.TargetConf < 0 {
+		return usageError("sat-per-byte and target-conf should not be negative")
+	}
+	if params.SatPerByte > 0 && params.TargetConf > 0 {
+		return usageError("Either sat-per-byte or target-conf can be specified")
+	}
+	if params.MinConfs < 0 {
+		return usageError("min-confs should not be negative")
+	}
+	if !params.PushAmount.LessThan(capacity) {
+		return usageError("Push amount should be less than capacity %s", formatAmount(capacity))
+	}
+	if l.MaxPushAmount.Sign() > 0 && params.PushAmount.GreaterThan(l.MaxPushAmount) {
+		return usageError("Push amount should be less than or equal %s", formatAmount(l.MaxPushAmount))
+	}
+	if l.MaxMinHtlc.Sign() > 0 && params.MinHtlc.GreaterThan(l.MaxMinHtlc) {
+		return usageError("Min HTLC should be less than or equal %s", formatAmount(l.MaxMinHtlc))
+	}
+	if params.RemoteCsvDelay < l.MinRemoteCsvDelay {
+		return usageError("Remote CSV delay should be greater than or equal %d blocks", l.MinRemoteCsvDelay)
+	}
+	if l.MaxRemoteCsvDelay > 0 && params.RemoteCsvDelay > l.MaxRemoteCsvDelay {
+		return usageError("Remote CSV delay should be less than or equal %d blocks", l.MaxRemoteCsvDelay)
+	}
+	if !params.Private && l.PublicAllowed != nil && !*l.PublicAllowed {
+		return usageError("Public channels are not allowed")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+func TestCheckOpenParams(t *testing.T) {
+	allowed, denied := true, false
+	limits := func(l clients.ChannelOpenLimits) *clients.Limits {
+		return &clients.Limits{ChannelOpen: l}
+	}
+	strict := clients.ChannelOpenLimits{
+		MinRemoteCsvDelay: 144,
+		MaxRemoteCsvDelay: 2016,
+		MaxPushAmount:     btc("0.001"),
+		MaxMinHtlc:        btc("0.00001"),
+		PublicAllowed:     &denied,
+	}
+	valid := clients.OpenChannelParams{RemoteCsvDelay: 144, Private: true, MinConfs: 1}
+	with := func(f func(p *clients.OpenChannelParams)) clients.OpenChannelParams {
+		p := valid
+		f(&p)
+		return p
+	}
+	tests := []struct {
+		name   string
+		params clients.OpenChannelParams
+		limits clients.ChannelOpenLimits
+		err    string
+	}{
+		{"valid", valid, strict, ""},
+		{"unconstrained", with(func(p *clients.OpenChannelParams) {
+			p.RemoteCsvDelay, p.PushAmount, p.MinHtlc, p.Private = 10000, btc("0.005"), btc("0.001"), false
+		}), clients.ChannelOpenLimits{}, ""},
+		{"public allowed", with(func(p *clients.OpenChannelParams) { p.Private = false }), clients.ChannelOpenLimits{PublicAllowed: &allowed}, ""},
+		{"public denied", with(func(p *clients.OpenChannelParams) { p.Private = false }), strict, "Public channels are not allowed"},
+		{"negative fee rate", with(func(p *clients.OpenChannelParams) { p.SatPerByte = -1 }), strict, "sat-per-byte and target-conf should not be negative"},
+		{"negative target", with(func(p *clients.OpenChannelParams) { p.TargetConf = -1 }), strict, "sat-per-byte and target-conf should not be negative"},
+		{"fee rate and target", with(func(p *clients.OpenChannelParams) { p.SatPerByte, p.TargetConf = 10, 6 }), strict, "Either sat-per-byte or target-conf can be specified"},
+		{"negative min confs", with(func(p *clients.OpenChannelParams) { p.MinConfs = -1 }), strict, "min-confs should not be negative"},
+		{"zero min confs", with(func(p *clients.OpenChannelParams) { p.MinConfs = 0 }), strict, ""},
+		{"push capacity", with(func(p *clients.OpenChannelParams) { p.PushAmount = btc("0.01") }), clients.ChannelOpenLimits{}, "Push amount should be less than capacity 0.01"},
+		{"push above max", with(func(p *clients.OpenChannelParams) { p.PushAmount = btc("0.002") }), strict, "Push amount should be less than or equal 0.001"},
+		{"push max", with(func(p *clients.OpenChannelParams) { p.PushAmount = btc("0.001") }), strict, ""},
+		{"min htlc above max", with(func(p *clients.OpenChannelParams) { p.MinHtlc = btc("0.00002") }), strict, "Min HTLC should be less than or equal 0.00001"},
+		{"csv delay below min", with(func(p *clients.OpenChannelParams) { p.RemoteCsvDelay = 143 }), strict, "Remote CSV delay should be greater than or equal 144 blocks"},
+		{"csv delay above max", with(func(p *clients.OpenChannelParams) { p.RemoteCsvDelay = 2017 }), strict, "Remote CSV delay should be less than or equal 2016 blocks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOpenParams(tt.params, btc("0.01"), limits(tt.limits))
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+			assert.Equal(t, CodeUsage, errorCode(err))
+		})
+	}
+}
+
+func TestCheckAmount(t *testing.T) {
+	defer SetUnit(UnitBTC)
+	tests := []struct {
+		unit   string
+		amount string
+		err    string
+	}{
+		{UnitBTC, "0.001", ""},
+		{UnitBTC, "0", "capacity should be positive"},
+		{UnitBTC, "-0.001", "capacity should be positive"},
+		{UnitBTC, "0.0015", "capacity 0.0015 should be a multiple of 0.001"},
+		{UnitSat, "0.0015", "capacity 150000 sat should be a multiple of 100000 sat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unit+" "+tt.amount, func(t *testing.T) {
+			require.NoError(t, SetUnit(tt.unit))
+			err := checkAmount("capacity", btc(tt.amount), channelFundingPrecision)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	limits := &clients.Limits{MinChannelCapacity: btc("0.001"), MinPaymentAmount: btc("0.00001")}
+
+	assert.NoError(t, checkPaymentAmount(btc("0.00001"), limits))
+	err := checkPaymentAmount(btc("0.000009"), limits)
+	assert.EqualError(t, err, "Amount should be greater or equal to min payment amount 0.00001")
+	assert.Equal(t, 2, ExitCode(err))
+
+	assert.NoError(t, checkChannelCapacity(btc("0.001"), limits))
+	err = checkChannelCapacity(btc("0.0009"), limits)
+	assert.EqualError(t, err, "Capacity should be greater than or equal 0.001")
+	assert.Equal(t, 2, ExitCode(err))
+}
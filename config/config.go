@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli"
+)
+
+// DefaultProfile name
+const DefaultProfile = "default"
+
+// SecretKey of raw API secret flag which is never stored in profiles, they refer to it
+// with api-secret-file or api-secret-agent instead
+const SecretKey = "api-secret"
+
+// Keys of profile settings, each one is a name of global flag it provides value for
+var Keys = []string{
+	"api-url",
+	"api-key",
+	"api-secret-file",
+	"api-secret-agent",
+	"lnd-host",
+	"lnd-tls-cert",
+	"lnd-macaroon",
+}
+
+// Profile settings by key
+type Profile map[string]string
+
+// File of configuration
+type File struct {
+	Profiles map[string]Profile `toml:"profiles"`
+}
+
+// Dir of daccs-cli configuration and data files
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "daccs-cli"), nil
+}
+
+// DefaultPath of configuration file
+func DefaultPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// Load configuration file, missing file means empty configuration
+func Load(path string) (*File, error) {
+	if path == "" {
+		p, err := DefaultPath()
+		if err != nil {
+			return nil, fmt.Errorf("Error %s on resolving config path", err)
+		}
+		path = p
+	}
+	f := &File{Profiles: map[string]Profile{}}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return f, nil
+	}
+	if _, err := toml.DecodeFile(path, f); err != nil {
+		return nil, fmt.Errorf("Error %s on reading config %s", err, path)
+	}
+	if f.Profiles == nil {
+		f.Profiles = map[string]Profile{}
+	}
+	return f, nil
+}
+
+// Save configuration file
+func (f *File) Save(path string) error {
+	if path == "" {
+		p, err := DefaultPath()
+		if err != nil {
+			return fmt.Errorf("Error %s on resolving config path", err)
+		}
+		path = p
+	}
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(f); err != nil {
+		return fmt.Errorf("Error %s on encoding config", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Error %s on creating config directory", err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("Error %s on writing config %s", err, path)
+	}
+	return nil
+}
+
+// ProfileNames sorted
+func (f *File) ProfileNames() []string {
+	res := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// IsKey checks if key is a known profile setting
+func IsKey(key string) bool {
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply profile selected by profile flag to global flags of the app context.
+// Flags set on the command line or by env vars keep their values, raw api-secret is skipped with a warning
+func Apply(c *cli.Context) error {
+	f, err := Load(c.String("config"))
+	if err != nil {
+		return err
+	}
+	name := c.String("profile")
+	profile, ok := f.Profiles[name]
+	if !ok {
+		if c.IsSet("profile") {
+			return fmt.Errorf("Unknown profile %s", name)
+		}
+		return nil
+	}
+	for key, value := range profile {
+		// Raw secret written by earlier versions is skipped so config set can still remove it
+		if key == SecretKey {
+			fmt.Fprintf(os.Stderr, "Warning: setting %s of profile %s is ignored, import it with secret import, set api-secret-file "+
+				"and remove it with config set %s \"\"\n", key, name, key)
+			continue
+		}
+		if !IsKey(key) {
+			return fmt.Errorf("Unknown setting %s in profile %s", key, name)
+		}
+		if c.IsSet(key) || envIsSet(c.App.Flags, key) {
+			continue
+		}
+		if err = c.Set(key, value); err != nil {
+			return fmt.Errorf("Error %s on applying %s from profile %s", err, key, name)
+		}
+	}
+	return nil
+}
+
+// envIsSet checks if env var of the named flag is set
+func envIsSet(flags []cli.Flag, name string) bool {
+	for _, f := range flags {
+		sf, ok := f.(cli.StringFlag)
+		if !ok || sf.Name != name || sf.EnvVar == "" {
+			continue
+		}
+		_, set := os.LookupEnv(sf.EnvVar)
+		return set
+	}
+	return false
+}
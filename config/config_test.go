@@ -0,0 +1,101 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func TestLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daccs-cli-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "config.toml")
+
+	f, err := Load(path)
+	require.NoError(t, err)
+	assert.Len(t, f.Profiles, 0)
+
+	f.Profiles["test"] = Profile{"api-url": "http://127.0.0.1:8080/"}
+	f.Profiles[DefaultProfile] = Profile{"api-key": "key-1"}
+	require.NoError(t, f.Save(path))
+
+	f, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{DefaultProfile, "test"}, f.ProfileNames())
+	assert.Equal(t, "key-1", f.Profiles[DefaultProfile]["api-key"])
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("profiles = ["), 0600))
+	_, err = Load(path)
+	require.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daccs-cli-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.toml")
+	f := &File{Profiles: map[string]Profile{
+		DefaultProfile: {"api-url": "http://default/", "api-key": "default-key"},
+		"test":         {"api-url": "http://test/"},
+		"secret":       {"api-key": "secret-key", SecretKey: "3077"},
+		"unknown":      {"lnd-port": "10009"},
+	}}
+	require.NoError(t, f.Save(path))
+
+	os.Setenv("DACCS_TEST_API_KEY", "env-key")
+	defer os.Unsetenv("DACCS_TEST_API_KEY")
+	tests := []struct {
+		name   string
+		args   []string
+		apiURL string
+		apiKey string
+		err    string
+	}{
+		{"default profile", nil, "http://default/", "", ""},
+		{"selected profile", []string{"--profile", "test"}, "http://test/", "", ""},
+		{"flag keeps its value", []string{"--api-url", "http://flag/"}, "http://flag/", "", ""},
+		{"raw secret is skipped", []string{"--profile", "secret"}, "", "", ""},
+		{"unknown profile", []string{"--profile", "other"}, "", "", "Unknown profile other"},
+		{"unknown setting", []string{"--profile", "unknown"}, "", "", "Unknown setting lnd-port in profile unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags := []cli.Flag{
+				cli.StringFlag{Name: "config", Value: path},
+				cli.StringFlag{Name: "profile", Value: DefaultProfile},
+				cli.StringFlag{Name: "api-url"},
+				cli.StringFlag{Name: "api-key", EnvVar: "DACCS_TEST_API_KEY"},
+				cli.StringFlag{Name: SecretKey},
+			}
+			for _, f := range flags {
+				f.Apply(set)
+			}
+			require.NoError(t, set.Parse(tt.args))
+			app := cli.NewApp()
+			app.Flags = flags
+			c := cli.NewContext(app, set, nil)
+
+			err := Apply(c)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			if tt.apiURL != "" {
+				assert.Equal(t, tt.apiURL, c.String("api-url"))
+			}
+			// Value of env var is kept
+			assert.Equal(t, "env-key", c.String("api-key"))
+			assert.Equal(t, "", c.String(SecretKey))
+		})
+	}
+}
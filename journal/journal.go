@@ -0,0 +1,185 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xenaex/daccs-cli/config"
+)
+
+// Payment statuses
+const (
+	StatusIssued    = "issued"
+	StatusInFlight  = "in_flight"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Entry of issued invoice and its payment
+type Entry struct {
+	ExternalID     string          `json:"external_id"`
+	AccountID      int64           `json:"account_id"`
+	Amount         decimal.Decimal `json:"amount"`
+	ChannelPoint   string          `json:"channel_point"`
+	PaymentRequest string          `json:"payment_request"`
+	PaymentHash    string          `json:"payment_hash"`
+	Preimage       string          `json:"preimage,omitempty"`
+	Status         string          `json:"status"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Journal of issued external ids persisted in a JSON file.
+// The file is read under a shared lock and rewritten under an exclusive one,
+// so concurrent commands do not lose each other's entries
+type Journal struct {
+	path    string
+	lock    *os.File
+	entries []*Entry
+}
+
+// DefaultPath of journal file
+func DefaultPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.json"), nil
+}
+
+// Open journal file, missing file means empty journal
+func Open(path string) (*Journal, error) {
+	if path == "" {
+		p, err := DefaultPath()
+		if err != nil {
+			return nil, fmt.Errorf("Error %s on resolving journal path", err)
+		}
+		path = p
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("Error %s on creating journal directory", err)
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on opening journal lock %s.lock", err, path)
+	}
+	j := &Journal{path: path, lock: lock}
+	err = j.locked(false, func() error {
+		entries, err := j.load()
+		j.entries = entries
+		return err
+	})
+	if err != nil {
+		j.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Close journal lock file
+func (j *Journal) Close() error {
+	return j.lock.Close()
+}
+
+// Get entry by account and external id, nil if not found
+func (j *Journal) Get(accountID int64, externalID string) *Entry {
+	for _, e := range j.entries {
+		if e.AccountID == accountID && e.ExternalID == externalID {
+			return e
+		}
+	}
+	return nil
+}
+
+// Find entries matching the predicate
+func (j *Journal) Find(match func(*Entry) bool) []*Entry {
+	res := []*Entry{}
+	for _, e := range j.entries {
+		if match(e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Put entry, replacing the one with the same account and external id, and save journal
+// along with the entries put by other commands since it was read
+func (j *Journal) Put(entry *Entry) error {
+	now := time.Now().UTC()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	entry.UpdatedAt = now
+	return j.locked(true, func() error {
+		entries, err := j.load()
+		if err != nil {
+			return err
+		}
+		replaced := false
+		for i, e := range entries {
+			if e.AccountID == entry.AccountID && e.ExternalID == entry.ExternalID {
+				entries[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			entries = append(entries, entry)
+		}
+		if err = j.save(entries); err != nil {
+			return err
+		}
+		j.entries = entries
+		return nil
+	})
+}
+
+// locked runs f holding journal lock, exclusive one if f modifies the journal file
+func (j *Journal) locked(exclusive bool, f func() error) error {
+	if err := lockFile(j.lock, exclusive); err != nil {
+		return fmt.Errorf("Error %s on locking journal %s", err, j.path)
+	}
+	defer unlockFile(j.lock)
+	return f()
+}
+
+// load entries from journal file
+func (j *Journal) load() ([]*Entry, error) {
+	data, err := ioutil.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on reading journal %s", err, j.path)
+	}
+	var entries []*Entry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("Error %s on parsing journal %s", err, j.path)
+	}
+	return entries, nil
+}
+
+// save journal atomically
+func (j *Journal) save(entries []*Entry) error {
+	data, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return fmt.Errorf("Error %s on creating journal directory", err)
+	}
+	tmp := j.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("Error %s on writing journal %s", err, tmp)
+	}
+	if err = os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("Error %s on writing journal %s", err, j.path)
+	}
+	return nil
+}
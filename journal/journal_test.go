@@ -0,0 +1,111 @@
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutGetFind(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+
+	j, err := Open(path)
+	require.NoError(t, err)
+	assert.Nil(t, j.Get(1, "dep-1"))
+	require.NoError(t, j.Put(&Entry{AccountID: 1, ExternalID: "dep-1", Amount: decimal.New(1, -3), Status: StatusIssued}))
+	require.NoError(t, j.Put(&Entry{AccountID: 1, ExternalID: "dep-2/1", Status: StatusIssued}))
+	require.NoError(t, j.Put(&Entry{AccountID: 1, ExternalID: "dep-2/2", Status: StatusIssued}))
+	require.NoError(t, j.Put(&Entry{AccountID: 2, ExternalID: "dep-1", Status: StatusIssued}))
+
+	e := j.Get(1, "dep-1")
+	require.NotNil(t, e)
+	assert.Equal(t, "0.001", e.Amount.String())
+	assert.False(t, e.CreatedAt.IsZero())
+	created := e.CreatedAt
+
+	// Put replaces the entry of the same account and external id
+	e.Status = StatusSucceeded
+	require.NoError(t, j.Put(e))
+	assert.Len(t, j.Find(func(e *Entry) bool { return e.ExternalID == "dep-1" }), 2)
+	parts := j.Find(func(e *Entry) bool { return e.AccountID == 1 && strings.HasPrefix(e.ExternalID, "dep-2/") })
+	require.Len(t, parts, 2)
+	assert.Equal(t, "dep-2/1", parts[0].ExternalID)
+	assert.Equal(t, "dep-2/2", parts[1].ExternalID)
+	assert.Len(t, j.Find(func(e *Entry) bool { return false }), 0)
+	require.NoError(t, j.Close())
+
+	// Entries are persisted
+	j, err = Open(path)
+	require.NoError(t, err)
+	defer j.Close()
+	e = j.Get(1, "dep-1")
+	require.NotNil(t, e)
+	assert.Equal(t, StatusSucceeded, e.Status)
+	assert.True(t, e.CreatedAt.Equal(created))
+	assert.Len(t, j.Find(func(e *Entry) bool { return true }), 4)
+}
+
+func TestConcurrentJournals(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+
+	// Open journals do not block each other
+	opened := make(chan *Journal, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			j, err := Open(path)
+			if err != nil {
+				panic(err)
+			}
+			opened <- j
+		}()
+	}
+	journals := []*Journal{}
+	for i := 0; i < 2; i++ {
+		select {
+		case j := <-opened:
+			defer j.Close()
+			journals = append(journals, j)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Open is blocked by the other open journal")
+		}
+	}
+
+	// Entries put via one journal are kept by the other one
+	require.NoError(t, journals[0].Put(&Entry{AccountID: 1, ExternalID: "dep-1", Status: StatusIssued}))
+	require.NoError(t, journals[1].Put(&Entry{AccountID: 1, ExternalID: "dep-2", Status: StatusIssued}))
+	assert.NotNil(t, journals[1].Get(1, "dep-1"))
+	require.NoError(t, journals[0].Put(&Entry{AccountID: 1, ExternalID: "dep-1", Status: StatusSucceeded}))
+
+	j, err := Open(path)
+	require.NoError(t, err)
+	defer j.Close()
+	require.NotNil(t, j.Get(1, "dep-1"))
+	assert.Equal(t, StatusSucceeded, j.Get(1, "dep-1").Status)
+	assert.NotNil(t, j.Get(1, "dep-2"))
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(path, []byte("not json"), 0600))
+
+	_, err := Open(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "on parsing journal")
+}
+
+// tempJournal path in temp dir removed by cleanup
+func tempJournal(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "daccs-cli-journal")
+	require.NoError(t, err)
+	return filepath.Join(dir, "journal.json"), func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package journal
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile waiting for other holders, shared lock is held along with other shared ones
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+// unlockFile locked by lockFile
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
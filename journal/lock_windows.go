@@ -0,0 +1,39 @@
+package journal
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const lockfileExclusiveLock = 0x2
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockFile waiting for other holders, shared lock is held along with other shared ones
+func lockFile(f *os.File, exclusive bool) error {
+	var flags uintptr
+	if exclusive {
+		flags = lockfileExclusiveLock
+	}
+	ol := &syscall.Overlapped{}
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile locked by lockFile
+func unlockFile(f *os.File) error {
+	ol := &syscall.Overlapped{}
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
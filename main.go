@@ -2,9 +2,12 @@ package main
 
 import (
 	"os"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli"
 	"github.com/xenaex/daccs-cli/commands"
+	"github.com/xenaex/daccs-cli/config"
 )
 
 func main() {
@@ -15,6 +18,34 @@ func main() {
 	app.Usage = "control your funds directly while accessing the speed and liquidity inherent to centralized exchanges"
 	// Common flags
 	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "config",
+			Usage:  "Path of configuration file (default ~/.config/daccs-cli/config.toml)",
+			EnvVar: "XENA_DACCS_CONFIG",
+		},
+		cli.StringFlag{
+			Name:   "profile",
+			Usage:  "Name of configuration file profile to take settings from, flags and env vars override them",
+			Value:  config.DefaultProfile,
+			EnvVar: "XENA_DACCS_PROFILE",
+		},
+		cli.StringFlag{
+			Name:   "output",
+			Usage:  "Output format: " + strings.Join(commands.OutputFormats, ", "),
+			Value:  commands.OutputJSON,
+			EnvVar: "XENA_DACCS_OUTPUT",
+		},
+		cli.StringFlag{
+			Name:   "unit",
+			Usage:  "Unit of amount flags and output: " + strings.Join(commands.Units, ", ") + ", amount flags also accept suffixes like 250000sat",
+			Value:  commands.UnitBTC,
+			EnvVar: "XENA_DACCS_UNIT",
+		},
+		cli.StringFlag{
+			Name:   "fiat",
+			Usage:  "Fiat currency, e.g. USD, to value output amounts in at Xena mark price",
+			EnvVar: "XENA_DACCS_FIAT",
+		},
 		cli.StringFlag{
 			Name:   "api-url",
 			Usage:  "URL of Xena dAccs API",
@@ -31,6 +62,34 @@ func main() {
 			Usage:  "API Secret for Xena dAccs API",
 			EnvVar: "XENA_DACCS_API_SECRET",
 		},
+		cli.StringFlag{
+			Name:   "api-secret-file",
+			Usage:  "Path of API secret file encrypted by secret import, used if api-secret is not specified",
+			EnvVar: "XENA_DACCS_API_SECRET_FILE",
+		},
+		cli.StringFlag{
+			Name:   "api-secret-agent",
+			Usage:  "Socket of secret agent to obtain API secret from, used if api-secret is not specified",
+			EnvVar: "XENA_DACCS_API_SECRET_AGENT",
+		},
+		cli.IntFlag{
+			Name:   "api-retries",
+			Usage:  "Number of retries of idempotent Xena dAccs API calls failed with network error, 429 or 5xx status",
+			Value:  3,
+			EnvVar: "XENA_DACCS_API_RETRIES",
+		},
+		cli.DurationFlag{
+			Name:   "api-retry-min-wait",
+			Usage:  "Wait before the first retry of Xena dAccs API call, doubled for every next retry",
+			Value:  500 * time.Millisecond,
+			EnvVar: "XENA_DACCS_API_RETRY_MIN_WAIT",
+		},
+		cli.DurationFlag{
+			Name:   "api-retry-max-wait",
+			Usage:  "Max wait between retries of Xena dAccs API call unless Retry-After asks for more",
+			Value:  10 * time.Second,
+			EnvVar: "XENA_DACCS_API_RETRY_MAX_WAIT",
+		},
 		cli.StringFlag{
 			Name:   "lnd-host",
 			Usage:  "Host address (optionally with :port) of local LND node",
@@ -49,19 +108,44 @@ func main() {
 			Value:  "admin.macaroon",
 			EnvVar: "XENA_DACCS_LND_MACAROON",
 		},
+		cli.StringFlag{
+			Name:   "journal",
+			Usage:  "Path of local journal of issued invoices (default ~/.config/daccs-cli/journal.json)",
+			EnvVar: "XENA_DACCS_JOURNAL",
+		},
+	}
+
+	app.Before = func(c *cli.Context) error {
+		// Config commands manage profiles, including the ones which could not be applied
+		if c.Args().First() != commands.Config.Name {
+			if err := config.Apply(c); err != nil {
+				return err
+			}
+		}
+		if err := commands.SetUnit(c.String("unit")); err != nil {
+			return err
+		}
+		if err := commands.SetFiat(c, c.String("fiat")); err != nil {
+			return err
+		}
+		return commands.SetOutputFormat(c.String("output"))
 	}
 
 	// Commands
-	app.Commands = []cli.Command{
+	app.OnUsageError = commands.OnUsageError
+	app.Commands = commands.WithUsageErrors([]cli.Command{
 		commands.Channel,
 		commands.Node,
 		commands.Payment,
 		commands.Api,
-	}
+		commands.Config,
+		commands.Secret,
+		commands.MockAPI,
+	})
 
 	err := app.Run(os.Args)
 	if err != nil {
-		commands.ResponseError(&commands.Error{Error: err.Error()})
-		os.Exit(1)
+		commands.ResponseError(err)
+		os.Exit(commands.ExitCode(err))
 	}
 }
@@ -0,0 +1,502 @@
+package mockapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/shopspring/decimal"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+var (
+	invoicesPath      = regexp.MustCompile(`^accounts/(\d+)/invoices$`)
+	invoiceStatusPath = regexp.MustCompile(`^invoices/([0-9a-f]{64})$`)
+	settlePath        = regexp.MustCompile(`^invoices/([0-9a-f]{64})/settle$`)
+	withdrawalsPath   = regexp.MustCompile(`^accounts/(\d+)/withdrawals$`)
+	balancePath       = regexp.MustCompile(`^accounts/(\d+)/balance$`)
+	depositsPath      = regexp.MustCompile(`^accounts/(\d+)/deposits$`)
+	markPricePath     = regexp.MustCompile(`^market-data/mark-price/([A-Z]+)$`)
+)
+
+// Config of mock API server
+type Config struct {
+	// APIKey expected in X-AUTH-API-KEY header, any key accepted if empty
+	APIKey string
+	// APIPubKey to verify request signatures with
+	APIPubKey *ecdsa.PublicKey
+	// NodeKey to sign issued invoices with
+	NodeKey *btcec.PrivateKey
+	// Net invoices are issued for
+	Net *chaincfg.Params
+	// Nodes returned by nodes endpoint
+	Nodes []*clients.Node
+	// Limits returned by limits endpoint
+	Limits clients.Limits
+	// Accounts returned by accounts endpoint, balances and deposits are served for them only
+	Accounts []*clients.Account
+	// MarkPrices by symbol
+	MarkPrices map[string]decimal.Decimal
+}
+
+// Server of mock Xena dAccs API
+type Server struct {
+	cfg Config
+
+	mu          sync.Mutex
+	lastNonce   map[string]int64
+	pubKeys     map[string]*pubKeyInfo
+	invoices    map[string][]*clients.Invoice
+	statuses    map[string]*clients.InvoiceStatus
+	withdrawals map[string]*clients.Withdrawal
+	deposits    []*clients.Deposit
+	preimages   map[string][]byte
+	nextPubKey  uint32
+}
+
+// NewServer constructor
+func NewServer(cfg Config) (*Server, error) {
+	if cfg.APIPubKey == nil {
+		return nil, errors.New("API public key is not specified")
+	}
+	if cfg.NodeKey == nil {
+		return nil, errors.New("node key is not specified")
+	}
+	if cfg.Net == nil {
+		return nil, errors.New("network is not specified")
+	}
+	return &Server{
+		cfg:         cfg,
+		lastNonce:   map[string]int64{},
+		pubKeys:     map[string]*pubKeyInfo{},
+		invoices:    map[string][]*clients.Invoice{},
+		statuses:    map[string]*clients.InvoiceStatus{},
+		withdrawals: map[string]*clients.Withdrawal{},
+		preimages:   map[string][]byte{},
+	}, nil
+}
+
+// Preimage of an issued invoice by its payment hash
+func (s *Server) Preimage(paymentHash string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.preimages[paymentHash]
+	return p, ok
+}
+
+// Settle issued invoice by its payment hash as paid with amount or withdrawal of the invoice with
+// the payment hash as paid with its own amount, false if there is no such invoice or withdrawal
+func (s *Server) Settle(paymentHash string, amount decimal.Decimal) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	if st, ok := s.statuses[paymentHash]; ok {
+		st.Status = clients.InvoiceSettled
+		st.Amount = amount
+		found = true
+	}
+	for _, d := range s.deposits {
+		if d.PaymentHash == paymentHash {
+			d.Status = clients.InvoiceSettled
+			d.Amount = amount
+		}
+	}
+	for _, wd := range s.withdrawals {
+		if wd.PaymentHash == paymentHash {
+			wd.Status = clients.InvoiceSettled
+			found = true
+		}
+	}
+	return found
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.authenticate(r); err != nil {
+		respondError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "pubkey" && r.Method == http.MethodPost:
+		s.registerPubKey(w, r)
+	case path == "nodes" && r.Method == http.MethodGet:
+		respondJSON(w, s.cfg.Nodes)
+	case path == "limits" && r.Method == http.MethodGet:
+		respondJSON(w, s.cfg.Limits)
+	case invoicesPath.MatchString(path) && r.Method == http.MethodPost:
+		accountID, _ := strconv.ParseInt(invoicesPath.FindStringSubmatch(path)[1], 10, 64)
+		s.issueInvoices(w, r, accountID)
+	case withdrawalsPath.MatchString(path) && r.Method == http.MethodPost:
+		accountID, _ := strconv.ParseInt(withdrawalsPath.FindStringSubmatch(path)[1], 10, 64)
+		s.requestWithdrawal(w, r, accountID)
+	case path == "accounts" && r.Method == http.MethodGet:
+		respondJSON(w, s.cfg.Accounts)
+	case balancePath.MatchString(path) && r.Method == http.MethodGet:
+		accountID, _ := strconv.ParseInt(balancePath.FindStringSubmatch(path)[1], 10, 64)
+		s.balance(w, accountID)
+	case depositsPath.MatchString(path) && r.Method == http.MethodGet:
+		accountID, _ := strconv.ParseInt(depositsPath.FindStringSubmatch(path)[1], 10, 64)
+		s.depositsList(w, r, accountID)
+	case markPricePath.MatchString(path) && r.Method == http.MethodGet:
+		symbol := markPricePath.FindStringSubmatch(path)[1]
+		price, ok := s.cfg.MarkPrices[symbol]
+		if !ok {
+			respondError(w, http.StatusNotFound, fmt.Errorf("symbol %s not found", symbol))
+			return
+		}
+		respondJSON(w, &clients.MarkPrice{Symbol: symbol, Price: price, Timestamp: time.Now().UTC()})
+	case invoiceStatusPath.MatchString(path) && r.Method == http.MethodGet:
+		s.invoiceStatus(w, invoiceStatusPath.FindStringSubmatch(path)[1])
+	case settlePath.MatchString(path) && r.Method == http.MethodPost:
+		s.settle(w, r, settlePath.FindStringSubmatch(path)[1])
+	default:
+		respondError(w, http.StatusNotFound, fmt.Errorf("%s %s not found", r.Method, r.URL.Path))
+	}
+}
+
+// authenticate request by X-AUTH-API-* headers
+func (s *Server) authenticate(r *http.Request) error {
+	apiKey := r.Header.Get("X-AUTH-API-KEY")
+	if apiKey == "" || (s.cfg.APIKey != "" && apiKey != s.cfg.APIKey) {
+		return errors.New("invalid api key")
+	}
+	nonce, err := strconv.ParseInt(r.Header.Get("X-AUTH-API-NONCE"), 10, 64)
+	if err != nil {
+		return errors.New("invalid nonce")
+	}
+	payload := r.Header.Get("X-AUTH-API-PAYLOAD")
+	if payload != fmt.Sprintf("AUTH%d", nonce) {
+		return errors.New("invalid payload")
+	}
+	sig, err := hex.DecodeString(r.Header.Get("X-AUTH-API-SIGNATURE"))
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	digest := sha256.Sum256([]byte(payload))
+	if !verify(s.cfg.APIPubKey, digest[:], sig) {
+		return errors.New("invalid signature")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if nonce <= s.lastNonce[apiKey] {
+		return errors.New("nonce is too small")
+	}
+	s.lastNonce[apiKey] = nonce
+	return nil
+}
+
+// registerPubKey handler
+func (s *Server) registerPubKey(w http.ResponseWriter, r *http.Request) {
+	req := &addPubKeyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.PubKey == "" {
+		respondError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.pubKeys[req.PubKey]
+	if ok {
+		info.Exists = true
+	} else {
+		s.nextPubKey++
+		info = &pubKeyInfo{ID: s.nextPubKey, PubKey: req.PubKey}
+		s.pubKeys[req.PubKey] = info
+	}
+	respondJSON(w, info)
+}
+
+// issueInvoices handler, repeated requests with the same external id get the same invoices
+func (s *Server) issueInvoices(w http.ResponseWriter, r *http.Request, accountID int64) {
+	req := &invoiceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.ExternalID == "" || len(req.ChanPoints) == 0 {
+		respondError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	if len(s.cfg.Nodes) == 0 {
+		respondError(w, http.StatusServiceUnavailable, errors.New("no nodes available"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%d/%s", accountID, req.ExternalID)
+	if invoices, ok := s.invoices[key]; ok {
+		respondJSON(w, invoices)
+		return
+	}
+	invoices := make([]*clients.Invoice, 0, len(req.ChanPoints))
+	for _, cp := range req.ChanPoints {
+		payReq, hash, preimage, err := s.newPaymentRequest(fmt.Sprintf("dAccs deposit to account %d", accountID))
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.preimages[hash] = preimage
+		s.statuses[hash] = &clients.InvoiceStatus{
+			PaymentHash: hash,
+			AccountID:   accountID,
+			ExternalID:  req.ExternalID,
+			Status:      clients.InvoiceOpen,
+		}
+		s.deposits = append(s.deposits, &clients.Deposit{
+			AccountID:   accountID,
+			ExternalID:  req.ExternalID,
+			PaymentHash: hash,
+			Status:      clients.InvoiceOpen,
+			CreatedAt:   time.Now().UTC(),
+		})
+		invoices = append(invoices, &clients.Invoice{
+			NodeID:         s.cfg.Nodes[0].ID,
+			PaymentRequest: payReq,
+			ChanPoint:      cp,
+		})
+	}
+	s.invoices[key] = invoices
+	respondJSON(w, invoices)
+}
+
+// account configured by id, nil if not found
+func (s *Server) account(accountID int64) *clients.Account {
+	for _, a := range s.cfg.Accounts {
+		if a.ID == accountID {
+			return a
+		}
+	}
+	return nil
+}
+
+// balance handler, balance is the sum of settled deposits
+func (s *Server) balance(w http.ResponseWriter, accountID int64) {
+	a := s.account(accountID)
+	if a == nil {
+		respondError(w, http.StatusNotFound, fmt.Errorf("account %d not found", accountID))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := &clients.AccountBalance{AccountID: accountID, Currency: a.Currency}
+	for _, d := range s.deposits {
+		if d.AccountID == accountID && d.Status == clients.InvoiceSettled {
+			res.Total = res.Total.Add(d.Amount)
+		}
+	}
+	res.Available = res.Total
+	respondJSON(w, res)
+}
+
+// depositsList handler, last deposits first
+func (s *Server) depositsList(w http.ResponseWriter, r *http.Request, accountID int64) {
+	if s.account(accountID) == nil {
+		respondError(w, http.StatusNotFound, fmt.Errorf("account %d not found", accountID))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := queryInt(r, "limit", 10)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := []*clients.Deposit{}
+	for i := len(s.deposits) - 1; i >= 0; i-- {
+		if s.deposits[i].AccountID != accountID {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if len(res) == limit {
+			break
+		}
+		res = append(res, s.deposits[i])
+	}
+	respondJSON(w, res)
+}
+
+// queryInt parameter of request, def if missing
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return i, nil
+}
+
+// invoiceStatus handler
+func (s *Server) invoiceStatus(w http.ResponseWriter, paymentHash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.statuses[paymentHash]
+	if !ok {
+		respondError(w, http.StatusNotFound, fmt.Errorf("invoice %s not found", paymentHash))
+		return
+	}
+	respondJSON(w, st)
+}
+
+// settle handler, stands in for the payment of an issued invoice or withdrawal the mock server can't make itself
+func (s *Server) settle(w http.ResponseWriter, r *http.Request, paymentHash string) {
+	req := &settleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Amount.Sign() <= 0 {
+		respondError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	if !s.Settle(paymentHash, req.Amount) {
+		respondError(w, http.StatusNotFound, fmt.Errorf("invoice %s not found", paymentHash))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.statuses[paymentHash]; ok {
+		respondJSON(w, st)
+		return
+	}
+	for _, wd := range s.withdrawals {
+		if wd.PaymentHash == paymentHash {
+			respondJSON(w, wd)
+			return
+		}
+	}
+}
+
+// requestWithdrawal handler, withdrawals are recorded as pending since mock server does not pay invoices,
+// settle endpoint marks them paid. Repeated requests with the same payment request get the same withdrawal
+func (s *Server) requestWithdrawal(w http.ResponseWriter, r *http.Request, accountID int64) {
+	req := &withdrawalRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.PaymentRequest == "" || req.ChanPoint == "" {
+		respondError(w, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	inv, err := zpay32.Decode(req.PaymentRequest, s.cfg.Net)
+	if err != nil || inv.MilliSat == nil || inv.PaymentHash == nil {
+		respondError(w, http.StatusBadRequest, errors.New("invalid payment request"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if wd, ok := s.withdrawals[req.PaymentRequest]; ok {
+		respondJSON(w, wd)
+		return
+	}
+	wd := &clients.Withdrawal{
+		ID:          strconv.Itoa(len(s.withdrawals) + 1),
+		AccountID:   accountID,
+		PaymentHash: hex.EncodeToString(inv.PaymentHash[:]),
+		Amount:      decimal.New(int64(*inv.MilliSat), -11),
+		Status:      "pending",
+	}
+	s.withdrawals[req.PaymentRequest] = wd
+	respondJSON(w, wd)
+}
+
+// newPaymentRequest creates BOLT11 invoice without amount signed by the node key
+func (s *Server) newPaymentRequest(description string) (string, string, []byte, error) {
+	preimage := make([]byte, 32)
+	if _, err := rand.Read(preimage); err != nil {
+		return "", "", nil, err
+	}
+	hash := sha256.Sum256(preimage)
+	inv, err := zpay32.NewInvoice(s.cfg.Net, hash, time.Now(), zpay32.Description(description))
+	if err != nil {
+		return "", "", nil, err
+	}
+	payReq, err := inv.Encode(zpay32.MessageSigner{
+		SignCompact: func(h []byte) ([]byte, error) {
+			return btcec.SignCompact(btcec.S256(), s.cfg.NodeKey, h, true)
+		},
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return payReq, hex.EncodeToString(hash[:]), preimage, nil
+}
+
+// verify signature made by restClient: unpadded r and s concatenated
+func verify(pubKey *ecdsa.PublicKey, digest, sig []byte) bool {
+	for i := 1; i < len(sig); i++ {
+		if i > 32 || len(sig)-i > 32 {
+			continue
+		}
+		r := new(big.Int).SetBytes(sig[:i])
+		s := new(big.Int).SetBytes(sig[i:])
+		if ecdsa.Verify(pubKey, digest, r, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// respondJSON writes successful response
+func respondJSON(w http.ResponseWriter, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// respondError writes error response
+func respondError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&errorResponse{Error: err.Error()})
+}
+
+// addPubKeyRequest message
+type addPubKeyRequest struct {
+	PubKey string `json:"pubKey"`
+}
+
+// pubKeyInfo message
+type pubKeyInfo struct {
+	ID     uint32 `json:"id"`
+	PubKey string `json:"pubKey"`
+	Exists bool   `json:"exists"`
+}
+
+// invoiceRequest message
+type invoiceRequest struct {
+	ExternalID string   `json:"externalId"`
+	ChanPoints []string `json:"chanPoints"`
+}
+
+// withdrawalRequest message
+type withdrawalRequest struct {
+	PaymentRequest string `json:"paymentRequest"`
+	ChanPoint      string `json:"chanPoint"`
+}
+
+// settleRequest message
+type settleRequest struct {
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// error message
+type errorResponse struct {
+	Error string `json:"error"`
+}
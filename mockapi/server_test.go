@@ -0,0 +1,236 @@
+package mockapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xenaex/daccs-cli/clients"
+)
+
+func TestAuthenticate(t *testing.T) {
+	s, key := testServer(t)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request)
+		status  int
+		err     string
+	}{
+		{"valid", func(r *http.Request) { sign(r, key, 10) }, http.StatusOK, ""},
+		{"no api key", func(r *http.Request) {
+			sign(r, key, 20)
+			r.Header.Del("X-AUTH-API-KEY")
+		}, http.StatusUnauthorized, "invalid api key"},
+		{"wrong api key", func(r *http.Request) {
+			sign(r, key, 21)
+			r.Header.Set("X-AUTH-API-KEY", "other")
+		}, http.StatusUnauthorized, "invalid api key"},
+		{"payload of other nonce", func(r *http.Request) {
+			sign(r, key, 22)
+			r.Header.Set("X-AUTH-API-NONCE", "23")
+		}, http.StatusUnauthorized, "invalid payload"},
+		{"signed by other key", func(r *http.Request) { sign(r, otherKey, 24) }, http.StatusUnauthorized, "invalid signature"},
+		{"signature not hex", func(r *http.Request) {
+			sign(r, key, 25)
+			r.Header.Set("X-AUTH-API-SIGNATURE", "zz")
+		}, http.StatusUnauthorized, "invalid signature encoding"},
+		{"replayed nonce", func(r *http.Request) { sign(r, key, 10) }, http.StatusUnauthorized, "nonce is too small"},
+		{"smaller nonce", func(r *http.Request) { sign(r, key, 5) }, http.StatusUnauthorized, "nonce is too small"},
+		{"greater nonce", func(r *http.Request) { sign(r, key, 30) }, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/limits", nil)
+			tt.prepare(r)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Code)
+			if tt.err != "" {
+				assert.Equal(t, tt.err, responseError(t, w))
+			}
+		})
+	}
+}
+
+func TestIssueInvoices(t *testing.T) {
+	s, key := testServer(t)
+	c := &testClient{t: t, s: s, key: key}
+
+	var invoices []*clients.Invoice
+	c.call(http.MethodPost, "/accounts/1/invoices", `{"externalId":"dep-1","chanPoints":["tx1:0","tx2:0"]}`, http.StatusOK, &invoices)
+	require.Len(t, invoices, 2)
+	assert.Equal(t, "xena-1", invoices[0].NodeID)
+	assert.Equal(t, "tx1:0", invoices[0].ChanPoint)
+	assert.Equal(t, "tx2:0", invoices[1].ChanPoint)
+	assert.NotEqual(t, invoices[0].PaymentRequest, invoices[1].PaymentRequest)
+
+	// Repeated request with the same external id gets the same invoices
+	var repeated []*clients.Invoice
+	c.call(http.MethodPost, "/accounts/1/invoices", `{"externalId":"dep-1","chanPoints":["tx1:0","tx2:0"]}`, http.StatusOK, &repeated)
+	assert.Equal(t, invoices, repeated)
+
+	// The same external id of other account is a different deposit
+	var other []*clients.Invoice
+	c.call(http.MethodPost, "/accounts/2/invoices", `{"externalId":"dep-1","chanPoints":["tx1:0"]}`, http.StatusOK, &other)
+	require.Len(t, other, 1)
+	assert.NotEqual(t, invoices[0].PaymentRequest, other[0].PaymentRequest)
+
+	c.call(http.MethodPost, "/accounts/1/invoices", `{"chanPoints":["tx1:0"]}`, http.StatusBadRequest, nil)
+	c.call(http.MethodPost, "/accounts/1/invoices", `{"externalId":"dep-2"}`, http.StatusBadRequest, nil)
+
+	// Issued invoices are open deposits
+	var deposits []*clients.Deposit
+	c.call(http.MethodGet, "/accounts/1/deposits", "", http.StatusOK, &deposits)
+	require.Len(t, deposits, 2)
+	for _, d := range deposits {
+		assert.Equal(t, "dep-1", d.ExternalID)
+		assert.Equal(t, clients.InvoiceOpen, d.Status)
+		st := &clients.InvoiceStatus{}
+		c.call(http.MethodGet, "/invoices/"+d.PaymentHash, "", http.StatusOK, st)
+		assert.Equal(t, clients.InvoiceOpen, st.Status)
+		assert.Equal(t, int64(1), st.AccountID)
+		_, ok := s.Preimage(d.PaymentHash)
+		assert.True(t, ok)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	s, key := testServer(t)
+	c := &testClient{t: t, s: s, key: key}
+
+	var invoices []*clients.Invoice
+	c.call(http.MethodPost, "/accounts/1/invoices", `{"externalId":"dep-1","chanPoints":["tx1:0"]}`, http.StatusOK, &invoices)
+	require.Len(t, invoices, 1)
+	var deposits []*clients.Deposit
+	c.call(http.MethodGet, "/accounts/1/deposits", "", http.StatusOK, &deposits)
+	require.Len(t, deposits, 1)
+	hash := deposits[0].PaymentHash
+
+	c.call(http.MethodPost, "/invoices/"+hash+"/settle", `{"amount":"0"}`, http.StatusBadRequest, nil)
+	c.call(http.MethodPost, "/invoices/"+strings.Repeat("0", 64)+"/settle", `{"amount":"0.001"}`, http.StatusNotFound, nil)
+
+	st := &clients.InvoiceStatus{}
+	c.call(http.MethodPost, "/invoices/"+hash+"/settle", `{"amount":"0.001"}`, http.StatusOK, st)
+	assert.Equal(t, clients.InvoiceSettled, st.Status)
+	assert.Equal(t, "0.001", st.Amount.String())
+
+	st = &clients.InvoiceStatus{}
+	c.call(http.MethodGet, "/invoices/"+hash, "", http.StatusOK, st)
+	assert.Equal(t, clients.InvoiceSettled, st.Status)
+	balance := &clients.AccountBalance{}
+	c.call(http.MethodGet, "/accounts/1/balance", "", http.StatusOK, balance)
+	assert.Equal(t, "0.001", balance.Total.String())
+	assert.Equal(t, "0.001", balance.Available.String())
+
+	// Withdrawal invoice without amount is rejected, the one with amount is pending until settled
+	noAmount, _, _, err := s.newPaymentRequest("withdrawal")
+	require.NoError(t, err)
+	c.call(http.MethodPost, "/accounts/1/withdrawals", fmt.Sprintf(`{"paymentRequest":%q,"chanPoint":"tx1:0"}`, noAmount), http.StatusBadRequest, nil)
+	payReq := testPaymentRequest(t, s, 50000000)
+	wd := &clients.Withdrawal{}
+	c.call(http.MethodPost, "/accounts/1/withdrawals", fmt.Sprintf(`{"paymentRequest":%q,"chanPoint":"tx1:0"}`, payReq), http.StatusOK, wd)
+	assert.Equal(t, "pending", wd.Status)
+	assert.Equal(t, "0.0005", wd.Amount.String())
+
+	settled := &clients.Withdrawal{}
+	c.call(http.MethodPost, "/invoices/"+wd.PaymentHash+"/settle", `{"amount":"0.0005"}`, http.StatusOK, settled)
+	assert.Equal(t, wd.ID, settled.ID)
+	assert.Equal(t, clients.InvoiceSettled, settled.Status)
+	assert.True(t, s.Settle(wd.PaymentHash, decimal.New(5, -4)))
+}
+
+// testServer with random API and node keys serving account 1
+func testServer(t *testing.T) (*Server, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	nodeKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	s, err := NewServer(Config{
+		APIKey:    "key",
+		APIPubKey: &key.PublicKey,
+		NodeKey:   nodeKey,
+		Net:       &chaincfg.RegressionNetParams,
+		Nodes:     []*clients.Node{{ID: "xena-1", Address: hex.EncodeToString(nodeKey.PubKey().SerializeCompressed()) + "@127.0.0.1:9735"}},
+		Accounts:  []*clients.Account{{ID: 1, Currency: "BTC"}, {ID: 2, Currency: "BTC"}},
+	})
+	require.NoError(t, err)
+	return s, key
+}
+
+// testPaymentRequest with amount in millisatoshi signed by the server node key
+func testPaymentRequest(t *testing.T, s *Server, msat uint64) string {
+	preimage := make([]byte, 32)
+	_, err := rand.Read(preimage)
+	require.NoError(t, err)
+	inv, err := zpay32.NewInvoice(s.cfg.Net, sha256.Sum256(preimage), time.Now(), zpay32.Amount(lnwire.MilliSatoshi(msat)))
+	require.NoError(t, err)
+	payReq, err := inv.Encode(zpay32.MessageSigner{
+		SignCompact: func(h []byte) ([]byte, error) {
+			return btcec.SignCompact(btcec.S256(), s.cfg.NodeKey, h, true)
+		},
+	})
+	require.NoError(t, err)
+	return payReq
+}
+
+// sign request with key like restClient does
+func sign(r *http.Request, key *ecdsa.PrivateKey, nonce int64) {
+	payload := fmt.Sprintf("AUTH%d", nonce)
+	digest := sha256.Sum256([]byte(payload))
+	sr, ss, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	r.Header.Set("X-AUTH-API-KEY", "key")
+	r.Header.Set("X-AUTH-API-PAYLOAD", payload)
+	r.Header.Set("X-AUTH-API-SIGNATURE", hex.EncodeToString(append(sr.Bytes(), ss.Bytes()...)))
+	r.Header.Set("X-AUTH-API-NONCE", strconv.FormatInt(nonce, 10))
+}
+
+// testClient makes signed requests to server with increasing nonces
+type testClient struct {
+	t     *testing.T
+	s     *Server
+	key   *ecdsa.PrivateKey
+	nonce int64
+}
+
+// call server checking response status and decoding successful response into res if not nil
+func (c *testClient) call(method, path, body string, status int, res interface{}) {
+	c.nonce++
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	sign(r, c.key, c.nonce)
+	w := httptest.NewRecorder()
+	c.s.ServeHTTP(w, r)
+	require.Equal(c.t, status, w.Code, w.Body.String())
+	if res != nil {
+		require.NoError(c.t, json.Unmarshal(w.Body.Bytes(), res))
+	}
+}
+
+// responseError message of error response
+func responseError(t *testing.T, w *httptest.ResponseRecorder) string {
+	res := &errorResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+	return res.Error
+}
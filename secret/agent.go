@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xenaex/daccs-cli/config"
+)
+
+const agentTimeout = 5 * time.Second
+
+// DefaultAgentSocket path
+func DefaultAgentSocket() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "agent.sock"), nil
+}
+
+// Agent keeps decrypted secret in memory and hands it out over unix socket
+// accessible by the owner only
+type Agent struct {
+	listener net.Listener
+	secret   []byte
+}
+
+// NewAgent listening on socket path
+func NewAgent(socket string, secret []byte) (*Agent, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return nil, fmt.Errorf("Error %s on creating agent socket directory", err)
+	}
+	// Remove stale socket left by the agent killed without cleanup
+	if conn, err := net.DialTimeout("unix", socket, agentTimeout); err == nil {
+		conn.Close()
+	} else {
+		os.Remove(socket)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on listening on %s", err, socket)
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("Error %s on restricting access to %s", err, socket)
+	}
+	return &Agent{listener: l, secret: secret}, nil
+}
+
+// Serve connections until agent is closed
+func (a *Agent) Serve() error {
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			return err
+		}
+		conn.SetDeadline(time.Now().Add(agentTimeout))
+		fmt.Fprintln(conn, hex.EncodeToString(a.secret))
+		conn.Close()
+	}
+}
+
+// Close agent and remove its socket
+func (a *Agent) Close() error {
+	return a.listener.Close()
+}
+
+// FromAgent obtains secret from agent listening on socket path
+func FromAgent(socket string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", socket, agentTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on connecting to secret agent %s", err, socket)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on reading from secret agent %s", err, socket)
+	}
+	secret, err := hex.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on decoding secret from agent %s", err, socket)
+	}
+	return secret, nil
+}
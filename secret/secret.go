@@ -0,0 +1,188 @@
+package secret
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/xenaex/daccs-cli/config"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	fileVersion = 1
+	kdfScrypt   = "scrypt"
+	// kdfNone of plaintext secret file
+	kdfNone = "none"
+
+	// scrypt parameters recommended for interactive logins
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 32
+)
+
+// File of encrypted or plaintext secret
+type File struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n,omitempty"`
+	R          int    `json:"r,omitempty"`
+	P          int    `json:"p,omitempty"`
+	Salt       string `json:"salt,omitempty"`
+	Nonce      string `json:"nonce,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+	// Plaintext secret of unencrypted file
+	Plaintext string `json:"plaintext,omitempty"`
+}
+
+// DefaultPath of encrypted secret file of the profile
+func DefaultPath(profile string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secrets", profile+".json"), nil
+}
+
+// Encrypt secret with passphrase
+func Encrypt(secret, passphrase []byte) (*File, error) {
+	salt := make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &File{
+		Version:    fileVersion,
+		KDF:        kdfScrypt,
+		N:          scryptN,
+		R:          scryptR,
+		P:          scryptP,
+		Salt:       hex.EncodeToString(salt),
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, secret, nil)),
+	}, nil
+}
+
+// Plain secret file stored unencrypted, protected by file permissions only
+func Plain(secret []byte) *File {
+	return &File{Version: fileVersion, KDF: kdfNone, Plaintext: hex.EncodeToString(secret)}
+}
+
+// Encrypted file requires passphrase to decrypt
+func (f *File) Encrypted() bool {
+	return f.KDF != kdfNone
+}
+
+// Decrypt secret with passphrase, plaintext secret is returned as is
+func (f *File) Decrypt(passphrase []byte) ([]byte, error) {
+	if f.Version == fileVersion && f.KDF == kdfNone {
+		return hex.DecodeString(f.Plaintext)
+	}
+	if f.Version != fileVersion || f.KDF != kdfScrypt {
+		return nil, fmt.Errorf("unsupported secret file version %d kdf %s", f.Version, f.KDF)
+	}
+	salt, err := hex.DecodeString(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(f.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(f.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(passphrase, salt, f.N, f.R, f.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+	secret, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("invalid passphrase")
+	}
+	return secret, nil
+}
+
+// Load encrypted secret file
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on reading secret file %s", err, path)
+	}
+	f := &File{}
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("Error %s on parsing secret file %s", err, path)
+	}
+	return f, nil
+}
+
+// Save encrypted secret file
+func (f *File) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Error %s on creating secret file directory", err)
+	}
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("Error %s on writing secret file %s", err, path)
+	}
+	return nil
+}
+
+// LoadAndDecrypt secret file asking for passphrase interactively if it's encrypted
+func LoadAndDecrypt(path string) ([]byte, error) {
+	f, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if !f.Encrypted() {
+		return f.Decrypt(nil)
+	}
+	pwd, err := ReadPassword(fmt.Sprintf("Input passphrase for %s: ", path))
+	if err != nil {
+		return nil, err
+	}
+	secret, err := f.Decrypt(pwd)
+	if err != nil {
+		return nil, fmt.Errorf("Error %s on decrypting secret file %s", err, path)
+	}
+	return secret, nil
+}
+
+// ReadPassword from terminal without echo, prompt goes to stderr to keep stdout clean
+func ReadPassword(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	pwd, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr)
+	return pwd, err
+}
@@ -0,0 +1,153 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	secret := []byte("30770201010420deadbeef")
+	f, err := Encrypt(secret, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.True(t, f.Encrypted())
+	assert.Equal(t, kdfScrypt, f.KDF)
+	assert.Equal(t, "", f.Plaintext)
+	assert.NotContains(t, f.Ciphertext, "deadbeef")
+
+	other, err := Encrypt(secret, []byte("passphrase"))
+	require.NoError(t, err)
+	assert.NotEqual(t, f.Salt, other.Salt)
+	assert.NotEqual(t, f.Ciphertext, other.Ciphertext)
+
+	tests := []struct {
+		name       string
+		file       func() *File
+		passphrase string
+		err        string
+	}{
+		{"valid", func() *File { return f }, "passphrase", ""},
+		{"wrong passphrase", func() *File { return f }, "wrong", "invalid passphrase"},
+		{"empty passphrase", func() *File { return f }, "", "invalid passphrase"},
+		{"tampered", func() *File {
+			cp := *f
+			cp.Ciphertext = "00" + cp.Ciphertext[2:]
+			if cp.Ciphertext == f.Ciphertext {
+				cp.Ciphertext = "11" + cp.Ciphertext[2:]
+			}
+			return &cp
+		}, "passphrase", "invalid passphrase"},
+		{"invalid nonce", func() *File {
+			cp := *f
+			cp.Nonce = cp.Nonce[2:]
+			return &cp
+		}, "passphrase", "invalid nonce size"},
+		{"unsupported version", func() *File {
+			cp := *f
+			cp.Version = 2
+			return &cp
+		}, "passphrase", "unsupported secret file version 2 kdf scrypt"},
+		{"unsupported kdf", func() *File {
+			cp := *f
+			cp.KDF = "argon2"
+			return &cp
+		}, "passphrase", "unsupported secret file version 1 kdf argon2"},
+		{"plain", func() *File { return Plain(secret) }, "", ""},
+		{"plain ignores passphrase", func() *File { return Plain(secret) }, "passphrase", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.file().Decrypt([]byte(tt.passphrase))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, secret, res)
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daccs-cli-secret")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secret := []byte("30770201010420deadbeef")
+	f, err := Encrypt(secret, []byte("passphrase"))
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "secrets", "default.json")
+	require.NoError(t, f.Save(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, f, loaded)
+	res, err := loaded.Decrypt([]byte("passphrase"))
+	require.NoError(t, err)
+	assert.Equal(t, secret, res)
+
+	_, err = Load(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestSaveLoadPlain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daccs-cli-secret")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secret := []byte("30770201010420deadbeef")
+
+	path := filepath.Join(dir, "secrets", "default.json")
+	require.NoError(t, Plain(secret).Save(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	f, err := Load(path)
+	require.NoError(t, err)
+	assert.False(t, f.Encrypted())
+	// Plaintext file is decrypted without passphrase prompt
+	res, err := LoadAndDecrypt(path)
+	require.NoError(t, err)
+	assert.Equal(t, secret, res)
+}
+
+func TestAgent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available on every windows version")
+	}
+	dir, err := ioutil.TempDir("", "daccs-cli-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "agent.sock")
+	secret := []byte{0x30, 0x77, 0x02, 0x01}
+
+	a, err := NewAgent(socket, secret)
+	require.NoError(t, err)
+	go a.Serve()
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	res, err := FromAgent(socket)
+	require.NoError(t, err)
+	assert.Equal(t, secret, res)
+	res, err = FromAgent(socket)
+	require.NoError(t, err)
+	assert.Equal(t, secret, res)
+
+	require.NoError(t, a.Close())
+	_, err = FromAgent(socket)
+	assert.Error(t, err)
+}